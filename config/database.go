@@ -2,9 +2,11 @@ package config
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -18,9 +20,12 @@ func PostgreConnection() {
 	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", os.Getenv("POSTGRE_USER"), os.Getenv("POSTGRE_PASSWORD"), os.Getenv("POSTGRE_HOST"), os.Getenv("POSTGRE_DB"))
 
 	// Open a connection to the database using GORM.
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{NowFunc: func() time.Time {
-		return time.Now().UTC()
-	}})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+		Logger: logging.NewGormLogger(SQLLogLevel(), SQLSlowThreshold), // SQL logging is configured through SQL_LOG_LEVEL
+	})
 
 	if err != nil {
 		panic(err) // Panic if there is an error while connecting.
@@ -37,13 +42,28 @@ func PostgreConnection() {
 	// Attempt to ping the database until a successful connection is established or 10 seconds have passed.
 	for sqlDb.Ping() != nil {
 		if start.After(start.Add(10 * time.Second)) {
-			fmt.Println("Failed to connection database after 10 seconds")
+			slog.Error("failed to connect to database after 10 seconds")
 			break
 		}
 	}
 
-	fmt.Println("Connected to database: ", sqlDb.Ping() == nil)
+	slog.Info("connected to database", "reachable", sqlDb.Ping() == nil)
 	DB = db // Assign the DB instance to the global variable.
 }
 
 // endregion
+
+// region "CloseDatabase" closes the PostgreSQL connection pool, waiting for queries that have already started to finish.
+func CloseDatabase() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDb, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDb.Close()
+}
+
+// endregion
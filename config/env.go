@@ -2,13 +2,15 @@ package config
 
 import (
 	"github.com/joho/godotenv"
-	"log"
+	"log/slog"
+	"os"
 )
 
 // region "LoadEnv" loads environment variables from a .env file.
 func LoadEnv() {
 	if err := godotenv.Load(); err != nil {
-		log.Fatalf("Error loading .env file: %v", err)
+		slog.Error("failed to load .env file", "error", err)
+		os.Exit(1)
 	}
 }
 
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// SQLSlowThreshold is how long a query may take before it is logged as slow.
+const SQLSlowThreshold = 200 * time.Millisecond
+
+// region "SetupLogger" makes the structured logger configured by LOG_LEVEL (debug, info, warn, error) and LOG_FORMAT (json, text) the default.
+func SetupLogger() {
+	level := slog.LevelInfo
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			slog.Warn("ignoring invalid log level", "value", value)
+			level = slog.LevelInfo
+		}
+	}
+
+	format := os.Getenv("LOG_FORMAT")
+	if format == "" {
+		format = logging.FormatJSON
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, level, format))
+}
+
+// endregion
+
+// region "SQLLogLevel" returns which SQL statements are logged, from SQL_LOG_LEVEL (silent, error, warn, info). Defaults to warn, logging failed and slow queries.
+func SQLLogLevel() gormLogger.LogLevel {
+	switch strings.ToLower(os.Getenv("SQL_LOG_LEVEL")) {
+	case "silent":
+		return gormLogger.Silent
+	case "error":
+		return gormLogger.Error
+	case "info":
+		return gormLogger.Info
+	default:
+		return gormLogger.Warn
+	}
+}
+
+// endregion
@@ -0,0 +1,16 @@
+package config
+
+import "os"
+
+const defaultMetricsAddr = ":9091"
+
+// region "MetricsAddr" returns the address of the internal listener serving /metrics, from METRICS_ADDR.
+// It is kept off the public port so only the monitoring network can scrape it.
+func MetricsAddr() string {
+	if addr := os.Getenv("METRICS_ADDR"); addr != "" {
+		return addr
+	}
+	return defaultMetricsAddr
+}
+
+// endregion
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// region RateLimit defines a token bucket: Rate tokens are added per second, up to Burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// endregion
+
+// defaultSocketRateLimits are applied per user and socket event unless overridden by the environment.
+var defaultSocketRateLimits = map[string]RateLimit{
+	"sendMessage":          {Rate: 5, Burst: 10},
+	"editMessage":          {Rate: 2, Burst: 5},
+	"deleteMessage":        {Rate: 2, Burst: 5},
+	"updateMessageStarred": {Rate: 2, Burst: 5},
+	"readMessage":          {Rate: 5, Burst: 10},
+	"joinRoom":             {Rate: 5, Burst: 20},
+	"setStatus":            {Rate: 1, Burst: 3},
+	"sync":                 {Rate: 1, Burst: 3},
+	"heartbeat":            {Rate: 1, Burst: 3},
+}
+
+// region "SocketRateLimits" returns the rate limit of every socket event.
+// A limit can be overridden with SOCKET_RATE_LIMIT_<EVENT>="<rate>,<burst>", e.g. SOCKET_RATE_LIMIT_SENDMESSAGE="5,10".
+func SocketRateLimits() map[string]RateLimit {
+	limits := make(map[string]RateLimit, len(defaultSocketRateLimits))
+	for event, limit := range defaultSocketRateLimits {
+		limits[event] = limit
+
+		value := os.Getenv("SOCKET_RATE_LIMIT_" + strings.ToUpper(event))
+		if value == "" {
+			continue
+		}
+
+		rate, burst, found := strings.Cut(value, ",")
+		parsedRate, rateErr := strconv.ParseFloat(strings.TrimSpace(rate), 64)
+		parsedBurst, burstErr := strconv.Atoi(strings.TrimSpace(burst))
+		if !found || rateErr != nil || burstErr != nil || parsedRate <= 0 || parsedBurst < 1 {
+			slog.Warn("ignoring invalid socket rate limit", "event", event, "value", value)
+			continue
+		}
+		limits[event] = RateLimit{Rate: parsedRate, Burst: parsedBurst}
+	}
+	return limits
+}
+
+// endregion
+
+// region "SocketMaxRateLimitViolations" returns how many throttled events a socket may send per minute before it is disconnected.
+func SocketMaxRateLimitViolations() int {
+	if value, err := strconv.Atoi(os.Getenv("SOCKET_MAX_RATE_LIMIT_VIOLATIONS")); err == nil && value > 0 {
+		return value
+	}
+	return 20
+}
+
+// endregion
@@ -0,0 +1,21 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// region "RedisPool" initializes a Redis connection pool shared by the socket cluster and presence tracking.
+func RedisPool() *redis.Pool {
+	return &redis.Pool{
+		MaxIdle:     10,                // Idle connections kept ready for reuse.
+		IdleTimeout: 240 * time.Second, // Close connections idle for longer than this.
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", os.Getenv("REDIS_HOST"), redis.DialPassword(os.Getenv("REDIS_PASSWORD")))
+		},
+	}
+}
+
+// endregion
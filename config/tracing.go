@@ -0,0 +1,41 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultServiceName = "swiftchat-backend"
+
+// region "TraceExporter" creates the span exporter selected by OTEL_TRACES_EXPORTER: none (default), stdout or otlp.
+// The otlp exporter is configured through the standard OTEL_EXPORTER_OTLP_* variables.
+func TraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")) {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New()
+	case "otlp":
+		return otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", os.Getenv("OTEL_TRACES_EXPORTER"))
+	}
+}
+
+// endregion
+
+// region "TraceServiceName" returns the service name spans are reported under, from OTEL_SERVICE_NAME.
+func TraceServiceName() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+	return defaultServiceName
+}
+
+// endregion
@@ -1,7 +1,6 @@
 package controller
 
 import (
-	"context"
 	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
@@ -66,7 +65,7 @@ func (ctrl *authController) GoogleCallback(ctx *gin.Context) {
 	googleConfig := config.GoogleConfig() // Get Google OAuth configuration
 
 	// Exchange the authorization code for a token
-	token, err := googleConfig.Exchange(context.Background(), code)
+	token, err := googleConfig.Exchange(ctx.Request.Context(), code)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Code-Token Exchange Failed"))
 		return
@@ -88,9 +87,9 @@ func (ctrl *authController) GoogleCallback(ctx *gin.Context) {
 	}
 
 	// Check if the user ID from Google is unique in our system
-	if !ctrl.UserService.IsIdUnique(userData["id"].(string)) {
+	if !ctrl.UserService.IsIdUnique(ctx.Request.Context(), userData["id"].(string)) {
 		// If the user ID is not unique, it means the user already exists in our database
-		user, getUserErr := ctrl.UserService.GetUserById(userData["id"].(string))
+		user, getUserErr := ctrl.UserService.GetUserById(ctx.Request.Context(), userData["id"].(string))
 		if getUserErr != nil {
 			ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Failed to retrieve user by ID"))
 			return
@@ -204,13 +203,13 @@ func (ctrl *authController) SignUp(ctx *gin.Context) {
 	session.Save()
 
 	// Check if the username is unique
-	if !ctrl.UserService.IsUsernameUnique(signUpBody.UserName) {
+	if !ctrl.UserService.IsUsernameUnique(ctx.Request.Context(), signUpBody.UserName) {
 		ctx.JSON(http.StatusConflict, utils.NewErrorResponse("Conflict", "Username is already taken, must be unique"))
 		return
 	}
 
 	// Insert the new user into the database
-	userData, createErr := ctrl.UserService.Create(&userInsertObj)
+	userData, createErr := ctrl.UserService.Create(ctx.Request.Context(), &userInsertObj)
 	if createErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Failed to insert new user into database"))
 		return
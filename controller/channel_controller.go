@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/kwa0x2/swiftchat-backend/service"
+	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"net/http"
+)
+
+type IChannelController interface {
+	Create(ctx *gin.Context)
+	Subscribe(ctx *gin.Context)
+	Unsubscribe(ctx *gin.Context)
+}
+
+type channelController struct {
+	RoomService   service.IRoomService
+	SocketGateway gateway.ISocketGateway
+}
+
+func NewChannelController(roomService service.IRoomService, socketGateway gateway.ISocketGateway) IChannelController {
+	return &channelController{
+		RoomService:   roomService,
+		SocketGateway: socketGateway,
+	}
+}
+
+// region "Create" handles the request to create a new broadcast channel owned by the current user.
+func (ctrl *channelController) Create(ctx *gin.Context) {
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	roomId, createErr := ctrl.RoomService.CreateChannel(ctx.Request.Context(), userSessionInfo.ID)
+	if createErr != nil {
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error creating channel"))
+		return
+	}
+
+	// Let the owner's connected sockets receive the channel's broadcasts right away.
+	ctrl.SocketGateway.AddUserToRoom(userSessionInfo.ID, gateway.ChannelRoom(roomId))
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"room_id": roomId,
+	})
+}
+
+// endregion
+
+// region "Subscribe" handles the request to subscribe the current user to a channel.
+func (ctrl *channelController) Subscribe(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if subscribeErr := ctrl.RoomService.Subscribe(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID); subscribeErr != nil {
+		respondChannelError(ctx, subscribeErr, "Error subscribing to channel")
+		return
+	}
+
+	// Start delivering the channel's broadcasts to the user's connected sockets.
+	ctrl.SocketGateway.AddUserToRoom(userSessionInfo.ID, gateway.ChannelRoom(roomBody.RoomID.String()))
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Successfully subscribed to the channel"))
+}
+
+// endregion
+
+// region "Unsubscribe" handles the request to unsubscribe the current user from a channel.
+func (ctrl *channelController) Unsubscribe(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if unsubscribeErr := ctrl.RoomService.Unsubscribe(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID); unsubscribeErr != nil {
+		respondChannelError(ctx, unsubscribeErr, "Error unsubscribing from channel")
+		return
+	}
+
+	// Stop delivering the channel's broadcasts and events to the user's connected sockets.
+	roomId := roomBody.RoomID.String()
+	ctrl.SocketGateway.RemoveUserFromRoom(userSessionInfo.ID, gateway.ChannelRoom(roomId))
+	ctrl.SocketGateway.RemoveUserFromRoom(userSessionInfo.ID, roomId)
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Successfully unsubscribed from the channel"))
+}
+
+// endregion
+
+// region "respondChannelError" maps channel errors to HTTP responses.
+func respondChannelError(ctx *gin.Context, err error, fallbackMessage string) {
+	if errors.Is(err, service.ErrNotChannelRoom) {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", err.Error()))
+		return
+	}
+
+	respondMembershipError(ctx, err, fallbackMessage)
+}
+
+// endregion
@@ -32,7 +32,7 @@ func (ctrl *fileController) UploadFile(ctx *gin.Context) {
 	defer file.Close()
 
 	// Upload the file to the S3 bucket and retrieve the file URL.
-	fileURL, fileErr := ctrl.S3Service.UploadFile(file, header)
+	fileURL, fileErr := ctrl.S3Service.UploadFile(ctx.Request.Context(), file, header)
 	if fileErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error uploading file to S3 bucket"))
 		return
@@ -38,7 +38,7 @@ func (ctrl *friendController) GetFriends(ctx *gin.Context) {
 	}
 
 	// Retrieve the user's friends, with the second parameter 'false' indicating that only friends (not unfriended users) should be fetched.
-	friends, GetFriendsErr := ctrl.FriendService.GetFriends(userSessionInfo.Email, false)
+	friends, GetFriendsErr := ctrl.FriendService.GetFriends(ctx.Request.Context(), userSessionInfo.Email, false)
 	if GetFriendsErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving friends"))
 		return
@@ -70,7 +70,7 @@ func (ctrl *friendController) GetBlockedUsers(ctx *gin.Context) {
 	}
 
 	// Fetch blocked users from the FriendService
-	blockedUsers, GetBlockedUsersErr := ctrl.FriendService.GetBlockedUsers(userSessionInfo.Email)
+	blockedUsers, GetBlockedUsersErr := ctrl.FriendService.GetBlockedUsers(ctx.Request.Context(), userSessionInfo.Email)
 	if GetBlockedUsersErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving blocked users"))
 		return
@@ -109,7 +109,7 @@ func (ctrl *friendController) Block(ctx *gin.Context) {
 	}
 
 	// Block the user using the FriendService
-	friendStatus, blockErr := ctrl.FriendService.Block(actionBody.Email, userSessionInfo.Email)
+	friendStatus, blockErr := ctrl.FriendService.Block(ctx.Request.Context(), actionBody.Email, userSessionInfo.Email)
 	if blockErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error blocking the user"))
 		return
@@ -122,7 +122,7 @@ func (ctrl *friendController) Block(ctx *gin.Context) {
 	}
 
 	// Emit a socket event to notify about the blocked friend
-	ctrl.SocketGateway.EmitToNotificationRoom("blocked_friend", actionBody.Email, notifyData)
+	ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "blocked_friend", actionBody.Email, notifyData)
 	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("Success", "User has been successfully blocked"))
 }
 
@@ -146,7 +146,7 @@ func (ctrl *friendController) Delete(ctx *gin.Context) {
 	}
 
 	// Delete the friend using the FriendService
-	if err := ctrl.FriendService.Delete(actionBody.Email, userSessionInfo.Email); err != nil {
+	if err := ctrl.FriendService.Delete(ctx.Request.Context(), actionBody.Email, userSessionInfo.Email); err != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Delete Friend Error", "Error delete the user"))
 		return
 	}
@@ -157,7 +157,7 @@ func (ctrl *friendController) Delete(ctx *gin.Context) {
 	}
 
 	// Emit a socket event to notify about the deleted friend
-	ctrl.SocketGateway.EmitToNotificationRoom("deleted_friend", actionBody.Email, notifyData)
+	ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "deleted_friend", actionBody.Email, notifyData)
 	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("Success", "User has been successfully deleted"))
 }
 
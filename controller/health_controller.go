@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kwa0x2/swiftchat-backend/service"
+	"net/http"
+)
+
+type IHealthController interface {
+	Live(ctx *gin.Context)
+	Ready(ctx *gin.Context)
+}
+
+type healthController struct {
+	HealthService service.IHealthService
+	ShutdownCtx   context.Context // Cancelled when the app shuts down, so the instance stops receiving traffic.
+}
+
+func NewHealthController(shutdownCtx context.Context, healthService service.IHealthService) IHealthController {
+	return &healthController{
+		HealthService: healthService,
+		ShutdownCtx:   shutdownCtx,
+	}
+}
+
+// region "Live" reports that the process is running and able to serve requests.
+func (ctrl *healthController) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": service.HealthStatusUp})
+}
+
+// endregion
+
+// region "Ready" reports whether every dependency is reachable, with a breakdown of the failing ones.
+func (ctrl *healthController) Ready(ctx *gin.Context) {
+	// A shutting down instance should not receive new traffic.
+	if ctrl.ShutdownCtx.Err() != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	report := ctrl.HealthService.CheckReadiness(ctx.Request.Context())
+	if report.Status != service.HealthStatusUp {
+		ctx.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
+
+// endregion
@@ -1,8 +1,15 @@
 package controller
 
 import (
+	"errors"
 	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/socket/adapter"
+	"github.com/kwa0x2/swiftchat-backend/types"
+	"gorm.io/gorm"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/kwa0x2/swiftchat-backend/service"
@@ -11,15 +18,20 @@ import (
 
 type IMessageController interface {
 	GetMessageHistory(ctx *gin.Context)
+	SendMessage(ctx *gin.Context)
+	EditMessage(ctx *gin.Context)
+	DeleteMessage(ctx *gin.Context)
 }
 
 type messageController struct {
 	MessageService service.IMessageService
+	SocketAdapter  adapter.ISocketAdapter
 }
 
-func NewMessageController(messageService service.IMessageService) IMessageController {
+func NewMessageController(messageService service.IMessageService, socketAdapter adapter.ISocketAdapter) IMessageController {
 	return &messageController{
 		MessageService: messageService,
+		SocketAdapter:  socketAdapter,
 	}
 }
 
@@ -30,6 +42,33 @@ type MessageHistoryBody struct {
 
 // endregion
 
+// region SendMessageBody defines the structure for the request body to send a message.
+type SendMessageBody struct {
+	RoomID      uuid.UUID         `json:"room_id" binding:"required"`      // Unique identifier for the chat room.
+	Message     string            `json:"message" binding:"required"`      // Text of the message, or the file URL for attachments.
+	MessageType types.MessageType `json:"message_type" binding:"required"` // Type of the message.
+	UserEmail   string            `json:"user_email"`                      // Email of the recipient, not needed for channels.
+	ClientID    *string           `json:"client_message_id"`               // Optional sender-generated ID; retries with the same ID return the original message.
+}
+
+// endregion
+
+// region EditMessageBody defines the structure for the request body to edit a message.
+type EditMessageBody struct {
+	MessageID     uuid.UUID `json:"message_id" binding:"required"`     // Unique identifier for the message.
+	EditedMessage string    `json:"edited_message" binding:"required"` // New text of the message.
+}
+
+// endregion
+
+// region DeleteMessageBody defines the structure for the request body to delete a message.
+type DeleteMessageBody struct {
+	MessageID  uuid.UUID        `json:"message_id" binding:"required"` // Unique identifier for the message.
+	DeleteType types.DeleteType `json:"delete_type"`                   // "me" or "everyone", defaults to everyone.
+}
+
+// endregion
+
 // region "GetMessageHistory" handles the request to retrieve message history for a specific room.
 func (ctrl *messageController) GetMessageHistory(ctx *gin.Context) {
 	var messageHistoryBody MessageHistoryBody
@@ -40,10 +79,17 @@ func (ctrl *messageController) GetMessageHistory(ctx *gin.Context) {
 		return
 	}
 
-	// Retrieve message history data using the provided room ID.
-	messageHistoryData, err := ctrl.MessageService.GetMessageHistoryByRoomID(messageHistoryBody.RoomID)
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	// Retrieve message history data using the provided room ID, as seen by the current user.
+	messageHistoryData, err := ctrl.MessageService.GetMessageHistoryByRoomID(ctx.Request.Context(), messageHistoryBody.RoomID, userSessionInfo.ID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving message history by room id."))
+		respondMessageError(ctx, err, "Error retrieving message history by room id.")
 		return
 	}
 
@@ -51,3 +97,149 @@ func (ctrl *messageController) GetMessageHistory(ctx *gin.Context) {
 }
 
 // endregion
+
+// region "SendMessage" handles the request to send a message, sharing the socket send path.
+func (ctrl *messageController) SendMessage(ctx *gin.Context) {
+	var sendMessageBody SendMessageBody
+
+	// Bind JSON request body to the SendMessageBody struct.
+	if err := ctx.BindJSON(&sendMessageBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	// REST sends share the rate limit of the socket event.
+	if err := ctrl.SocketAdapter.CheckRateLimit(userSessionInfo.Email, "sendMessage"); err != nil {
+		respondMessageError(ctx, err, "Error sending message")
+		return
+	}
+
+	messageObj := models.Message{
+		SenderID:        userSessionInfo.ID,
+		Message:         sendMessageBody.Message,
+		RoomID:          sendMessageBody.RoomID,
+		MessageType:     sendMessageBody.MessageType,
+		ClientMessageID: sendMessageBody.ClientID,
+	}
+
+	messageId, sendErr := ctrl.SocketAdapter.SendMessage(ctx.Request.Context(), &messageObj, userSessionInfo.Email, sendMessageBody.UserEmail)
+	if sendErr != nil {
+		respondMessageError(ctx, sendErr, "Error sending message")
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{
+		"message_id": messageId,
+	})
+}
+
+// endregion
+
+// region "EditMessage" handles the request to edit a message, sharing the socket edit path.
+func (ctrl *messageController) EditMessage(ctx *gin.Context) {
+	var editMessageBody EditMessageBody
+
+	// Bind JSON request body to the EditMessageBody struct.
+	if err := ctx.BindJSON(&editMessageBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	// REST edits share the rate limit of the socket event.
+	if err := ctrl.SocketAdapter.CheckRateLimit(userSessionInfo.Email, "editMessage"); err != nil {
+		respondMessageError(ctx, err, "Error editing message")
+		return
+	}
+
+	if err := ctrl.SocketAdapter.EditMessage(ctx.Request.Context(), userSessionInfo.ID, editMessageBody.EditedMessage, editMessageBody.MessageID); err != nil {
+		respondMessageError(ctx, err, "Error editing message")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Message edited successfully"))
+}
+
+// endregion
+
+// region "DeleteMessage" handles the request to delete a message for the user or for everyone, sharing the socket delete path.
+func (ctrl *messageController) DeleteMessage(ctx *gin.Context) {
+	var deleteMessageBody DeleteMessageBody
+
+	// Bind JSON request body to the DeleteMessageBody struct.
+	if err := ctx.BindJSON(&deleteMessageBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	// REST deletes share the rate limit of the socket event.
+	if err := ctrl.SocketAdapter.CheckRateLimit(userSessionInfo.Email, "deleteMessage"); err != nil {
+		respondMessageError(ctx, err, "Error deleting message")
+		return
+	}
+
+	var deleteErr error
+	switch deleteMessageBody.DeleteType {
+	case types.DeleteForMe:
+		deleteErr = ctrl.SocketAdapter.DeleteMessageForMe(ctx.Request.Context(), userSessionInfo.ID, userSessionInfo.Email, deleteMessageBody.MessageID)
+	case types.DeleteForEveryone, "":
+		deleteErr = ctrl.SocketAdapter.DeleteMessage(ctx.Request.Context(), userSessionInfo.ID, deleteMessageBody.MessageID)
+	default:
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "invalid delete_type"))
+		return
+	}
+
+	if deleteErr != nil {
+		respondMessageError(ctx, deleteErr, "Error deleting message")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Message deleted successfully"))
+}
+
+// endregion
+
+// region "respondMessageError" maps message send, edit, delete and rate limit errors to HTTP responses.
+func respondMessageError(ctx *gin.Context, err error, fallbackMessage string) {
+	var slowModeErr *service.SlowModeError
+	var rateLimitErr *adapter.RateLimitError
+	switch {
+	case errors.Is(err, service.ErrInvalidClientID):
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", err.Error()))
+	case errors.As(err, &slowModeErr):
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(slowModeErr.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, utils.NewErrorResponse("Too Many Requests", err.Error()))
+	case errors.As(err, &rateLimitErr):
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, utils.NewErrorResponse("Too Many Requests", err.Error()))
+	case errors.Is(err, service.ErrFriendBlocked), errors.Is(err, service.ErrChannelPostForbidden),
+		errors.Is(err, service.ErrNotMessageSender), errors.Is(err, service.ErrNotMessageEditor), errors.Is(err, service.ErrDeleteWindowExpired):
+		ctx.JSON(http.StatusForbidden, utils.NewErrorResponse("Forbidden", err.Error()))
+	case errors.Is(err, service.ErrNotRoomMember), errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", err.Error()))
+	default:
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", fallbackMessage))
+	}
+}
+
+// endregion
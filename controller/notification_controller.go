@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	notificationPollInterval = 15 * time.Second // Longest wait for new notifications before a keep-alive comment is sent.
+	notificationRetryMillis  = 3000             // Reconnect delay suggested to EventSource clients.
+)
+
+type INotificationController interface {
+	Stream(ctx *gin.Context)
+}
+
+type notificationController struct {
+	NotificationStream cluster.INotificationStream
+	ShutdownCtx        context.Context // Cancelled when the app shuts down, so open streams end and let the server drain.
+}
+
+func NewNotificationController(shutdownCtx context.Context, notificationStream cluster.INotificationStream) INotificationController {
+	return &notificationController{
+		NotificationStream: notificationStream,
+		ShutdownCtx:        shutdownCtx,
+	}
+}
+
+// region "Stream" serves the user's notification-room events as server-sent events, for clients that cannot use socket.io.
+// Clients resume after the Last-Event-ID header (or the last_event_id query parameter); a "reset" event means some
+// notifications were no longer retained and the client has to reload its state.
+func (ctrl *notificationController) Stream(ctx *gin.Context) {
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	lastEventId := ctx.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = ctx.Query("last_event_id")
+	}
+
+	afterId, complete, err := ctrl.NotificationStream.Start(userSessionInfo.Email, lastEventId)
+	if err != nil {
+		if errors.Is(err, cluster.ErrInvalidNotificationID) {
+			ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error opening notification stream"))
+		return
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // Keep reverse proxies from buffering the stream.
+	ctx.Status(http.StatusOK)
+
+	fmt.Fprintf(ctx.Writer, "retry: %d\n\n", notificationRetryMillis)
+	if !complete {
+		fmt.Fprint(ctx.Writer, "event: reset\ndata: {}\n\n")
+	}
+	ctx.Writer.Flush()
+
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return // The client went away.
+		case <-ctrl.ShutdownCtx.Done():
+			return // The client reconnects to another instance and resumes there.
+		default:
+		}
+
+		notifications, readErr := ctrl.NotificationStream.Read(userSessionInfo.Email, afterId, notificationPollInterval)
+		if readErr != nil {
+			logging.FromContext(ctx.Request.Context()).Error("failed to read notifications", "user_id", userSessionInfo.ID, "error", readErr)
+			return // The client reconnects and resumes from the last delivered event.
+		}
+
+		if len(notifications) == 0 {
+			fmt.Fprint(ctx.Writer, ": keep-alive\n\n")
+		}
+
+		for _, notification := range notifications {
+			// Same payload as the socket.io notification room.
+			payload, _ := json.Marshal(map[string]interface{}{
+				"action": notification.Action,
+				"data":   notification.Data,
+			})
+			fmt.Fprintf(ctx.Writer, "id: %s\ndata: %s\n\n", notification.ID, payload)
+			afterId = notification.ID
+		}
+
+		ctx.Writer.Flush()
+	}
+}
+
+// endregion
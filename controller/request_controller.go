@@ -2,7 +2,6 @@ package controller
 
 import (
 	"errors"
-	"fmt"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
@@ -11,6 +10,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
 	"github.com/kwa0x2/swiftchat-backend/service"
 	"github.com/kwa0x2/swiftchat-backend/utils"
 )
@@ -50,7 +50,7 @@ func (ctrl *requestController) GetRequests(ctx *gin.Context) {
 	}
 
 	// Retrieve requests for the user.
-	data, GetReqErr := ctrl.RequestService.GetRequests(userSessionInfo.Email)
+	data, GetReqErr := ctrl.RequestService.GetRequests(ctx.Request.Context(), userSessionInfo.Email)
 	if GetReqErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving incoming requests"))
 		return
@@ -91,14 +91,14 @@ func (ctrl *requestController) Patch(ctx *gin.Context) {
 	}
 
 	// Update the friendship request status.
-	data, UpdateErr := ctrl.RequestService.UpdateFriendshipRequest(userSessionInfo.Email, requestBody.Email, requestBody.Status)
+	data, UpdateErr := ctrl.RequestService.UpdateFriendshipRequest(ctx.Request.Context(), userSessionInfo.Email, requestBody.Email, requestBody.Status)
 	if UpdateErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error updating friendship request"))
 		return
 	}
 
 	// Emit notification about the updated friendship request to the socket gateway.
-	ctrl.SocketGateway.EmitToNotificationRoom("update_friendship_request", requestBody.Email, data)
+	ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "update_friendship_request", requestBody.Email, data)
 	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("Success", "Friendship request updated successfully"))
 }
 
@@ -127,7 +127,7 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 	}
 
 	var pgErr *pgconn.PgError
-	existingFriend, GetSpecificFriendErr := ctrl.FriendService.GetSpecificFriend(requestObj.SenderMail, requestObj.ReceiverMail)
+	existingFriend, GetSpecificFriendErr := ctrl.FriendService.GetSpecificFriend(ctx.Request.Context(), requestObj.SenderMail, requestObj.ReceiverMail)
 	if GetSpecificFriendErr != nil && !errors.Is(GetSpecificFriendErr, gorm.ErrRecordNotFound) {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Unable to retrieve friend status"))
 		return
@@ -135,7 +135,7 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 
 	// Check for existing friendship status.
 	if existingFriend != nil {
-		fmt.Println("status", existingFriend.FriendStatus)
+		logging.FromContext(ctx.Request.Context()).Debug("existing friendship", "status", existingFriend.FriendStatus)
 		if existingFriend.FriendStatus == types.Friend {
 			ctx.JSON(http.StatusConflict, utils.NewErrorResponse("Already Friend", "Users are already friends"))
 			return
@@ -146,9 +146,9 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 	}
 
 	// Check if the receiver's email exists.
-	if isEmailExists := ctrl.UserService.IsEmailExists(requestObj.ReceiverMail); !isEmailExists {
+	if isEmailExists := ctrl.UserService.IsEmailExists(ctx.Request.Context(), requestObj.ReceiverMail); !isEmailExists {
 		// If not, create a new friend request.
-		if createErr := ctrl.RequestService.Create(nil, &requestObj); err != nil {
+		if createErr := ctrl.RequestService.Create(ctx.Request.Context(), nil, &requestObj); err != nil {
 			if errors.As(createErr, &pgErr) && pgErr.Code == "23505" {
 				ctx.JSON(http.StatusConflict, utils.NewErrorResponse("Already Sent", "Duplicate friend request"))
 				return
@@ -158,7 +158,7 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 		}
 
 		// Send email notification about the friend request.
-		_, SendEmailErr := ctrl.ResendService.SendEmail(requestObj.ReceiverMail, "You have received a new friend request from the SwiftChat app!", "friend_request")
+		_, SendEmailErr := ctrl.ResendService.SendEmail(ctx.Request.Context(), requestObj.ReceiverMail, "You have received a new friend request from the SwiftChat app!", "friend_request")
 		if SendEmailErr != nil {
 			ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Failed to send email"))
 			return
@@ -169,7 +169,7 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 	}
 
 	// If the email exists, insert and return user information.
-	data, dataErr := ctrl.RequestService.InsertAndReturnUser(&requestObj)
+	data, dataErr := ctrl.RequestService.InsertAndReturnUser(ctx.Request.Context(), &requestObj)
 	if dataErr != nil {
 		if errors.As(dataErr, &pgErr) && pgErr.Code == "23505" {
 			ctx.JSON(http.StatusConflict, utils.NewErrorResponse("Already Sent", "Duplicate friend request"))
@@ -180,7 +180,7 @@ func (ctrl *requestController) SendFriend(ctx *gin.Context) {
 	}
 
 	// Emit a notification about the friend request to the socket gateway.
-	ctrl.SocketGateway.EmitToNotificationRoom("friend_request", requestObj.ReceiverMail, data)
+	ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "friend_request", requestObj.ReceiverMail, data)
 	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("Friend Sent", "Friend request successfully sent"))
 }
 
@@ -1,16 +1,40 @@
 package controller
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/service"
+	"github.com/kwa0x2/swiftchat-backend/socket/adapter"
+	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
 	"github.com/kwa0x2/swiftchat-backend/types"
 	"github.com/kwa0x2/swiftchat-backend/utils"
+	"gorm.io/gorm"
 	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultMembersPageLimit = 50  // Members returned per page when no limit is given.
+	maxMembersPageLimit     = 100 // Upper bound for the members page size.
 )
 
 type IRoomController interface {
 	GetOrCreatePrivateRoom(ctx *gin.Context)
 	GetChatList(ctx *gin.Context)
+	ClearHistory(ctx *gin.Context)
+	RemoveFromChatList(ctx *gin.Context)
+	Leave(ctx *gin.Context)
+	Kick(ctx *gin.Context)
+	Ban(ctx *gin.Context)
+	GetMembers(ctx *gin.Context)
+	Mute(ctx *gin.Context)
+	Archive(ctx *gin.Context)
+	Pin(ctx *gin.Context)
+	SetSlowMode(ctx *gin.Context)
+	DeleteRoom(ctx *gin.Context)
 }
 
 type roomController struct {
@@ -18,14 +42,18 @@ type roomController struct {
 	UserRoomService service.IUserRoomService
 	UserService     service.IUserService
 	FriendService   service.IFriendService
+	SocketGateway   gateway.ISocketGateway
+	SocketAdapter   adapter.ISocketAdapter
 }
 
-func NewRoomController(roomService service.IRoomService, userRoomService service.IUserRoomService, userService service.IUserService, friendService service.IFriendService) IRoomController {
+func NewRoomController(roomService service.IRoomService, userRoomService service.IUserRoomService, userService service.IUserService, friendService service.IFriendService, socketGateway gateway.ISocketGateway, socketAdapter adapter.ISocketAdapter) IRoomController {
 	return &roomController{
 		RoomService:     roomService,
 		UserRoomService: userRoomService,
 		UserService:     userService,
 		FriendService:   friendService,
+		SocketGateway:   socketGateway,
+		SocketAdapter:   socketAdapter,
 	}
 }
 
@@ -37,6 +65,45 @@ type ActionBody struct {
 
 // endregion
 
+// region RoomBody represents the structure of the request body for actions on a single room.
+type RoomBody struct {
+	RoomID uuid.UUID `json:"room_id"` // Unique identifier for the chat room.
+}
+
+// endregion
+
+// region RoomMemberBody represents the structure of the request body for actions on a room member.
+type RoomMemberBody struct {
+	RoomID uuid.UUID `json:"room_id"` // Unique identifier for the chat room.
+	UserID string    `json:"user_id"` // Identifier of the member the action applies to.
+}
+
+// endregion
+
+// region MuteBody represents the structure of the request body for muting a room.
+type MuteBody struct {
+	RoomID     uuid.UUID  `json:"room_id"`     // Unique identifier for the chat room.
+	MutedUntil *time.Time `json:"muted_until"` // Time until which notifications are muted, null to unmute.
+}
+
+// endregion
+
+// region RoomToggleBody represents the structure of the request body for switching a per-user room setting.
+type RoomToggleBody struct {
+	RoomID  uuid.UUID `json:"room_id"` // Unique identifier for the chat room.
+	Enabled bool      `json:"enabled"` // Whether the setting is turned on.
+}
+
+// endregion
+
+// region SlowModeBody represents the structure of the request body for configuring slow mode.
+type SlowModeBody struct {
+	RoomID  uuid.UUID `json:"room_id"` // Unique identifier for the chat room.
+	Seconds int       `json:"seconds"` // Minimum seconds between messages of a member, 0 to disable.
+}
+
+// endregion
+
 // region "GetOrCreatePrivateRoom" handles the request to retrieve or create a private chat room.
 func (ctrl *roomController) GetOrCreatePrivateRoom(ctx *gin.Context) {
 	var actionBody ActionBody
@@ -55,34 +122,19 @@ func (ctrl *roomController) GetOrCreatePrivateRoom(ctx *gin.Context) {
 	}
 
 	// Fetch the user by email to find the other participant in the chat.
-	user, userErr := ctrl.UserService.GetByEmail(actionBody.Email)
+	user, userErr := ctrl.UserService.GetByEmail(ctx.Request.Context(), actionBody.Email)
 	if userErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Errors", "Error retrieving user by email"))
 		return
 	}
 
-	// Check if a private room already exists between the current user and the fetched user.
-	room, roomErr := ctrl.UserRoomService.GetPrivateRoom(userSessionInfo.ID, user.UserID)
+	// Reuse the private room between the current user and the fetched user, creating it if needed.
+	roomId, roomErr := ctrl.RoomService.GetOrCreatePrivateRoom(ctx.Request.Context(), userSessionInfo.ID, user.UserID)
 	if roomErr != nil {
-		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving private room"))
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error retrieving or creating private room"))
 		return
 	}
 
-	var roomId string
-
-	// If no room exists, create a new private room and add users to it.
-	if room == "" {
-		newRoomId, newRoomErr := ctrl.RoomService.CreateAndAddUsers(userSessionInfo.ID, user.UserID, "private")
-		if newRoomErr != nil {
-			// If there's an error creating the room, return an internal server error response.
-			ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error creating and adding users to room"))
-			return
-		}
-		roomId = newRoomId // Set the new room ID.
-	} else {
-		roomId = room // Use the existing room ID.
-	}
-
 	ctx.JSON(http.StatusOK, gin.H{
 		"room_id": roomId,
 	})
@@ -99,8 +151,11 @@ func (ctrl *roomController) GetChatList(ctx *gin.Context) {
 		return
 	}
 
+	// Archived rooms are only included when explicitly requested.
+	includeArchived := ctx.Query("archived") == "true"
+
 	// Fetch the user's chat list using their session information.
-	chatListData, chatListErr := ctrl.RoomService.GetChatList(userSessionInfo.ID, userSessionInfo.Email)
+	chatListData, chatListErr := ctrl.RoomService.GetChatList(ctx.Request.Context(), userSessionInfo.ID, userSessionInfo.Email, includeArchived)
 	if chatListErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Errors", "Error retrieving chat list"))
 		return
@@ -110,3 +165,400 @@ func (ctrl *roomController) GetChatList(ctx *gin.Context) {
 }
 
 // endregion
+
+// region "ClearHistory" handles the request to clear a room's message history for the current user only.
+func (ctrl *roomController) ClearHistory(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	// Move the user's history watermark for the room to now.
+	if clearErr := ctrl.UserRoomService.ClearHistory(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID); clearErr != nil {
+		if errors.Is(clearErr, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", "Room not found"))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error clearing chat history"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Chat history successfully cleared"))
+}
+
+// endregion
+
+// region "RemoveFromChatList" handles the request to delete a conversation from the current user's chat list.
+func (ctrl *roomController) RemoveFromChatList(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	// Clear the history and hide the room until a new message arrives.
+	if removeErr := ctrl.UserRoomService.RemoveFromChatList(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID); removeErr != nil {
+		if errors.Is(removeErr, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", "Room not found"))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error deleting conversation"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Conversation successfully deleted"))
+}
+
+// endregion
+
+// region "Leave" handles the request to leave a group room.
+func (ctrl *roomController) Leave(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if leaveErr := ctrl.UserRoomService.Leave(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID); leaveErr != nil {
+		respondMembershipError(ctx, leaveErr, "Error leaving room")
+		return
+	}
+
+	roomId := roomBody.RoomID.String()
+
+	// Stop delivering room events to the user's sockets and let the remaining members know.
+	ctrl.SocketGateway.RemoveUserFromRoom(userSessionInfo.ID, roomId)
+	ctrl.SocketGateway.EmitToRoomId(ctx.Request.Context(), "member_left", roomId, map[string]interface{}{
+		"room_id": roomBody.RoomID,
+		"user_id": userSessionInfo.ID,
+	})
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Successfully left the room"))
+}
+
+// endregion
+
+// region "Kick" handles the request to remove a member from a group room.
+func (ctrl *roomController) Kick(ctx *gin.Context) {
+	ctrl.removeMember(ctx, false)
+}
+
+// endregion
+
+// region "Ban" handles the request to remove a member from a group room and prevent them from rejoining.
+func (ctrl *roomController) Ban(ctx *gin.Context) {
+	ctrl.removeMember(ctx, true)
+}
+
+// endregion
+
+// region "removeMember" removes a member from a group room and notifies the room and the removed user.
+func (ctrl *roomController) removeMember(ctx *gin.Context, ban bool) {
+	var roomMemberBody RoomMemberBody
+
+	// Bind JSON request body to RoomMemberBody struct.
+	if err := ctx.BindJSON(&roomMemberBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if removeErr := ctrl.UserRoomService.RemoveMember(ctx.Request.Context(), userSessionInfo.ID, roomMemberBody.UserID, roomMemberBody.RoomID, ban); removeErr != nil {
+		respondMembershipError(ctx, removeErr, "Error removing member from room")
+		return
+	}
+
+	roomId := roomMemberBody.RoomID.String()
+	emitData := map[string]interface{}{
+		"room_id":    roomMemberBody.RoomID,
+		"user_id":    roomMemberBody.UserID,
+		"removed_by": userSessionInfo.ID,
+		"banned":     ban,
+	}
+
+	// Stop delivering room and channel events to the removed user's sockets and let the remaining members know.
+	ctrl.SocketGateway.RemoveUserFromRoom(roomMemberBody.UserID, roomId)
+	ctrl.SocketGateway.RemoveUserFromRoom(roomMemberBody.UserID, gateway.ChannelRoom(roomId))
+	ctrl.SocketGateway.EmitToRoomId(ctx.Request.Context(), "member_removed", roomId, emitData)
+
+	// Tell the removed user so their clients can drop the room.
+	if removedUser, userErr := ctrl.UserService.GetUserById(ctx.Request.Context(), roomMemberBody.UserID); userErr == nil {
+		ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "removed_from_room", removedUser.UserEmail, emitData)
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Member successfully removed from the room"))
+}
+
+// endregion
+
+// region "GetMembers" handles the request to list a page of a room's members with their presence.
+func (ctrl *roomController) GetMembers(ctx *gin.Context) {
+	roomId, err := uuid.Parse(ctx.Query("room_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "invalid room_id format"))
+		return
+	}
+
+	// Read the requested page, falling back to the first page of the default size.
+	page, pageErr := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	limit, limitErr := strconv.Atoi(ctx.DefaultQuery("limit", strconv.Itoa(defaultMembersPageLimit)))
+	if pageErr != nil || limitErr != nil || page < 1 || limit < 1 || limit > maxMembersPageLimit {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "invalid page or limit"))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	members, total, membersErr := ctrl.UserRoomService.GetMembers(ctx.Request.Context(), userSessionInfo.ID, roomId, page, limit)
+	if membersErr != nil {
+		respondMembershipError(ctx, membersErr, "Error retrieving room members")
+		return
+	}
+
+	// Look up the presence of the whole page at once.
+	users := make([]*models.User, 0, len(members))
+	for _, member := range members {
+		users = append(users, &member.User)
+	}
+	presences := ctrl.SocketAdapter.GetPresences(ctx.Request.Context(), userSessionInfo.Email, users)
+
+	// Prepare the response data by mapping the member information.
+	responseData := make([]map[string]interface{}, 0, len(members))
+	for _, member := range members {
+		presence := presences[member.User.UserEmail]
+		responseItem := map[string]interface{}{
+			"user_id":    member.UserID,         // Member's ID
+			"user_email": member.User.UserEmail, // Member's email
+			"user_name":  member.User.UserName,  // Member's username
+			"user_photo": member.User.UserPhoto, // Member's profile photo
+			"room_role":  member.RoomRole,       // Member's role in the room
+			"joinedAt":   member.CreatedAt,      // When the member joined the room
+			"online":     presence.Online,       // Whether the member is currently connected
+		}
+		responseData = append(responseData, responseItem) // Append the formatted item
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewPaginatedResponse(len(responseData), total, page, limit, responseData))
+}
+
+// endregion
+
+// region "Mute" handles the request to mute or unmute a room's notifications for the current user.
+func (ctrl *roomController) Mute(ctx *gin.Context) {
+	var muteBody MuteBody
+
+	// Bind JSON request body to MuteBody struct.
+	if err := ctx.BindJSON(&muteBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if muteErr := ctrl.UserRoomService.Mute(ctx.Request.Context(), userSessionInfo.ID, muteBody.RoomID, muteBody.MutedUntil); muteErr != nil {
+		respondMembershipError(ctx, muteErr, "Error updating room mute setting")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Room mute setting successfully updated"))
+}
+
+// endregion
+
+// region "Archive" handles the request to archive or unarchive a room for the current user.
+func (ctrl *roomController) Archive(ctx *gin.Context) {
+	var toggleBody RoomToggleBody
+
+	// Bind JSON request body to RoomToggleBody struct.
+	if err := ctx.BindJSON(&toggleBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if archiveErr := ctrl.UserRoomService.Archive(ctx.Request.Context(), userSessionInfo.ID, toggleBody.RoomID, toggleBody.Enabled); archiveErr != nil {
+		respondMembershipError(ctx, archiveErr, "Error updating room archive setting")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Room archive setting successfully updated"))
+}
+
+// endregion
+
+// region "Pin" handles the request to pin or unpin a room for the current user.
+func (ctrl *roomController) Pin(ctx *gin.Context) {
+	var toggleBody RoomToggleBody
+
+	// Bind JSON request body to RoomToggleBody struct.
+	if err := ctx.BindJSON(&toggleBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if pinErr := ctrl.UserRoomService.Pin(ctx.Request.Context(), userSessionInfo.ID, toggleBody.RoomID, toggleBody.Enabled); pinErr != nil {
+		respondMembershipError(ctx, pinErr, "Error updating room pin setting")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Room pin setting successfully updated"))
+}
+
+// endregion
+
+// region "SetSlowMode" handles the request to configure a room's slow mode.
+func (ctrl *roomController) SetSlowMode(ctx *gin.Context) {
+	var slowModeBody SlowModeBody
+
+	// Bind JSON request body to SlowModeBody struct.
+	if err := ctx.BindJSON(&slowModeBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if slowModeErr := ctrl.RoomService.SetSlowMode(ctx.Request.Context(), userSessionInfo.ID, slowModeBody.RoomID, slowModeBody.Seconds); slowModeErr != nil {
+		if errors.Is(slowModeErr, service.ErrInvalidSlowMode) {
+			ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", slowModeErr.Error()))
+			return
+		}
+		respondMembershipError(ctx, slowModeErr, "Error updating slow mode")
+		return
+	}
+
+	// Let members know the new posting interval.
+	ctrl.SocketGateway.EmitToRoomId(ctx.Request.Context(), "slow_mode_updated", slowModeBody.RoomID.String(), map[string]interface{}{
+		"room_id":           slowModeBody.RoomID,
+		"slow_mode_seconds": slowModeBody.Seconds,
+	})
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Slow mode successfully updated"))
+}
+
+// endregion
+
+// region "DeleteRoom" handles the request to delete a group room or channel for all of its members.
+func (ctrl *roomController) DeleteRoom(ctx *gin.Context) {
+	var roomBody RoomBody
+
+	// Bind JSON request body to RoomBody struct.
+	if err := ctx.BindJSON(&roomBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	memberEmails, deleteErr := ctrl.RoomService.DeleteRoom(ctx.Request.Context(), userSessionInfo.ID, roomBody.RoomID)
+	if deleteErr != nil {
+		respondMembershipError(ctx, deleteErr, "Error deleting room")
+		return
+	}
+
+	roomId := roomBody.RoomID.String()
+	emitData := map[string]interface{}{
+		"room_id":    roomBody.RoomID,
+		"deleted_by": userSessionInfo.ID,
+	}
+
+	// Notify open conversations and every former member's chat list.
+	ctrl.SocketGateway.EmitToRoomId(ctx.Request.Context(), "room_deleted", roomId, emitData)
+	for _, memberEmail := range memberEmails {
+		ctrl.SocketGateway.EmitToNotificationRoom(ctx.Request.Context(), "room_deleted", memberEmail, emitData)
+	}
+
+	// Stop routing events of the deleted room to any socket.
+	ctrl.SocketGateway.CloseRoom(roomId)
+	ctrl.SocketGateway.CloseRoom(gateway.ChannelRoom(roomId))
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Room successfully deleted"))
+}
+
+// endregion
+
+// region "respondMembershipError" maps room membership errors to HTTP responses.
+func respondMembershipError(ctx *gin.Context, err error, fallbackMessage string) {
+	switch {
+	case errors.Is(err, service.ErrNotRoomAdmin), errors.Is(err, service.ErrNotRoomOwner), errors.Is(err, service.ErrCannotRemoveMember),
+		errors.Is(err, service.ErrOwnerCannotLeave), errors.Is(err, service.ErrBannedFromRoom):
+		ctx.JSON(http.StatusForbidden, utils.NewErrorResponse("Forbidden", err.Error()))
+	case errors.Is(err, service.ErrNotGroupRoom):
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", err.Error()))
+	case errors.Is(err, service.ErrNotRoomMember), errors.Is(err, service.ErrRoomDeleted), errors.Is(err, gorm.ErrRecordNotFound):
+		ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", err.Error()))
+	case errors.Is(err, service.ErrAlreadyRoomMember):
+		ctx.JSON(http.StatusConflict, utils.NewErrorResponse("Already Member", err.Error()))
+	default:
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", fallbackMessage))
+	}
+}
+
+// endregion
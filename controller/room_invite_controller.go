@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/service"
+	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
+	"github.com/kwa0x2/swiftchat-backend/types"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"net/http"
+	"time"
+)
+
+type IRoomInviteController interface {
+	Create(ctx *gin.Context)
+	Revoke(ctx *gin.Context)
+	GetInvites(ctx *gin.Context)
+	Join(ctx *gin.Context)
+}
+
+type roomInviteController struct {
+	RoomInviteService service.IRoomInviteService
+	RoomService       service.IRoomService
+	SocketGateway     gateway.ISocketGateway
+}
+
+func NewRoomInviteController(roomInviteService service.IRoomInviteService, roomService service.IRoomService, socketGateway gateway.ISocketGateway) IRoomInviteController {
+	return &roomInviteController{
+		RoomInviteService: roomInviteService,
+		RoomService:       roomService,
+		SocketGateway:     socketGateway,
+	}
+}
+
+// region CreateInviteBody represents the structure of the request body for creating an invite.
+type CreateInviteBody struct {
+	RoomID    uuid.UUID  `json:"room_id"`    // The group room to invite to.
+	ExpiresAt *time.Time `json:"expires_at"` // Optional time after which the invite stops working.
+	MaxUses   *int       `json:"max_uses"`   // Optional number of times the invite can be used.
+}
+
+// endregion
+
+// region InviteBody represents the structure of the request body for actions on an existing invite.
+type InviteBody struct {
+	InviteID uuid.UUID `json:"invite_id"` // Identifier of the invite, used for revoking.
+	Token    string    `json:"token"`     // Invite token, used for joining.
+}
+
+// endregion
+
+// region "Create" handles the request to create an invite link for a group room.
+func (ctrl *roomInviteController) Create(ctx *gin.Context) {
+	var createInviteBody CreateInviteBody
+
+	// Bind JSON request body to CreateInviteBody struct.
+	if err := ctx.BindJSON(&createInviteBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Reject limits that would make the invite unusable from the start.
+	if createInviteBody.MaxUses != nil && *createInviteBody.MaxUses <= 0 {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "max_uses must be greater than zero"))
+		return
+	}
+	if createInviteBody.ExpiresAt != nil && !createInviteBody.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "expires_at must be in the future"))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	invite, createErr := ctrl.RoomInviteService.Create(ctx.Request.Context(), userSessionInfo.ID, createInviteBody.RoomID, createInviteBody.ExpiresAt, createInviteBody.MaxUses)
+	if createErr != nil {
+		respondInviteError(ctx, createErr, "Error creating invite")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invite)
+}
+
+// endregion
+
+// region "Revoke" handles the request to revoke an invite link.
+func (ctrl *roomInviteController) Revoke(ctx *gin.Context) {
+	var inviteBody InviteBody
+
+	// Bind JSON request body to InviteBody struct.
+	if err := ctx.BindJSON(&inviteBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	if revokeErr := ctrl.RoomInviteService.Revoke(ctx.Request.Context(), userSessionInfo.ID, inviteBody.InviteID); revokeErr != nil {
+		respondInviteError(ctx, revokeErr, "Error revoking invite")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Invite successfully revoked"))
+}
+
+// endregion
+
+// region "GetInvites" handles the request to list a room's invites along with who created and used them.
+func (ctrl *roomInviteController) GetInvites(ctx *gin.Context) {
+	roomId, err := uuid.Parse(ctx.Query("room_id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", "invalid room_id format"))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	invites, getErr := ctrl.RoomInviteService.GetInvitesByRoomID(ctx.Request.Context(), userSessionInfo.ID, roomId)
+	if getErr != nil {
+		respondInviteError(ctx, getErr, "Error retrieving invites")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewGetResponse(len(invites), invites))
+}
+
+// endregion
+
+// region "Join" handles the request to join a group room through an invite token.
+func (ctrl *roomInviteController) Join(ctx *gin.Context) {
+	var inviteBody InviteBody
+
+	// Bind JSON request body to InviteBody struct.
+	if err := ctx.BindJSON(&inviteBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, err := utils.GetUserSessionInfo(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", err.Error()))
+		return
+	}
+
+	invite, joinErr := ctrl.RoomInviteService.Join(ctx.Request.Context(), userSessionInfo.ID, inviteBody.Token)
+	if joinErr != nil {
+		respondInviteError(ctx, joinErr, "Error joining room")
+		return
+	}
+
+	// Let the new subscriber's connected sockets receive the channel's broadcasts right away.
+	// The user has already joined, so a failed lookup only delays the broadcasts until they reconnect.
+	room, roomErr := ctrl.RoomService.GetById(ctx.Request.Context(), invite.RoomID)
+	if roomErr != nil {
+		logging.FromContext(ctx.Request.Context()).Error("failed to get joined room", "room_id", invite.RoomID, "error", roomErr)
+	} else if room.RoomType == types.Channel {
+		ctrl.SocketGateway.AddUserToRoom(userSessionInfo.ID, gateway.ChannelRoom(invite.RoomID.String()))
+	}
+
+	// Let the current members know someone joined.
+	emitData := map[string]interface{}{
+		"room_id":   invite.RoomID,
+		"user_id":   userSessionInfo.ID,
+		"user_name": userSessionInfo.Name,
+		"invite_id": invite.InviteID,
+	}
+	ctrl.SocketGateway.EmitToRoomId(ctx.Request.Context(), "member_joined", invite.RoomID.String(), emitData)
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"room_id": invite.RoomID,
+	})
+}
+
+// endregion
+
+// region "respondInviteError" maps invite service errors to HTTP responses.
+func respondInviteError(ctx *gin.Context, err error, fallbackMessage string) {
+	switch {
+	case errors.Is(err, service.ErrInviteNotFound):
+		ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", err.Error()))
+	case errors.Is(err, service.ErrInviteRevoked), errors.Is(err, service.ErrInviteExpired), errors.Is(err, service.ErrInviteExhausted):
+		ctx.JSON(http.StatusGone, utils.NewErrorResponse("Invite Unavailable", err.Error()))
+	default:
+		respondMembershipError(ctx, err, fallbackMessage)
+	}
+}
+
+// endregion
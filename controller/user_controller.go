@@ -1,10 +1,12 @@
 package controller
 
 import (
+	"errors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/kwa0x2/swiftchat-backend/service"
 	"github.com/kwa0x2/swiftchat-backend/socket/adapter"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"github.com/kwa0x2/swiftchat-backend/utils"
 	"net/http"
 )
@@ -12,6 +14,8 @@ import (
 type IUserController interface {
 	UpdateUsername(ctx *gin.Context)
 	UploadProfilePhoto(ctx *gin.Context)
+	GetPresence(ctx *gin.Context)
+	UpdatePresenceVisibility(ctx *gin.Context)
 }
 
 type userController struct {
@@ -37,6 +41,13 @@ type UsernameUpdateBody struct {
 
 // endregion
 
+// region PresenceVisibilityBody represents the structure of the request body for updating presence privacy.
+type PresenceVisibilityBody struct {
+	PresenceVisibility types.PresenceVisibility `json:"presence_visibility"` // Who can see the user's online status and last-seen time.
+}
+
+// endregion
+
 // region "UpdateUsername" handles the request to update the user's username.
 func (ctrl *userController) UpdateUsername(ctx *gin.Context) {
 	var requestBody UsernameUpdateBody
@@ -56,7 +67,7 @@ func (ctrl *userController) UpdateUsername(ctx *gin.Context) {
 	}
 
 	// Update the user's username in the database using their email.
-	if err := ctrl.UserService.UpdateUserNameByMail(requestBody.UserName, userSessionInfo.Email); err != nil {
+	if err := ctrl.UserService.UpdateUserNameByMail(ctx.Request.Context(), requestBody.UserName, userSessionInfo.Email); err != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error updating username by email"))
 		return
 	}
@@ -72,7 +83,7 @@ func (ctrl *userController) UpdateUsername(ctx *gin.Context) {
 	}
 
 	// Emit the username update notification to friends using the socket adapter.
-	if err := ctrl.SocketAdapter.EmitToFriendsAndSentRequests("update_username", userSessionInfo.Email, emitData); err != nil {
+	if err := ctrl.SocketAdapter.EmitToFriendsAndSentRequests(ctx.Request.Context(), "update_username", userSessionInfo.Email, emitData); err != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Failed to emit update username notification to friends"))
 		return
 	}
@@ -102,14 +113,14 @@ func (ctrl *userController) UploadProfilePhoto(ctx *gin.Context) {
 	}
 
 	// Upload the file to the S3 bucket and retrieve the file URL.
-	fileURL, UploadErr := ctrl.S3Service.UploadFile(file, header)
+	fileURL, UploadErr := ctrl.S3Service.UploadFile(ctx.Request.Context(), file, header)
 	if UploadErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error uploading file to S3 bucket"))
 		return
 	}
 
 	// Update the user's photo in the database using their email.
-	if UpdateErr := ctrl.UserService.UpdateUserPhotoByMail(fileURL, userSessionInfo.Email); UpdateErr != nil {
+	if UpdateErr := ctrl.UserService.UpdateUserPhotoByMail(ctx.Request.Context(), fileURL, userSessionInfo.Email); UpdateErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error updating user photo"))
 		return
 	}
@@ -127,7 +138,7 @@ func (ctrl *userController) UploadProfilePhoto(ctx *gin.Context) {
 	}
 
 	// Emit the photo update notification to friends using the socket adapter.
-	if EmitErr := ctrl.SocketAdapter.EmitToFriendsAndSentRequests("update_user_photo", userSessionInfo.Email, emitData); EmitErr != nil {
+	if EmitErr := ctrl.SocketAdapter.EmitToFriendsAndSentRequests(ctx.Request.Context(), "update_user_photo", userSessionInfo.Email, emitData); EmitErr != nil {
 		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Failed to emit update user photo notification to friends"))
 		return
 	}
@@ -136,3 +147,55 @@ func (ctrl *userController) UploadProfilePhoto(ctx *gin.Context) {
 }
 
 // endregion
+
+// region "GetPresence" handles the request to retrieve a user's online status and last-seen time.
+func (ctrl *userController) GetPresence(ctx *gin.Context) {
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	// Fetch the user whose presence is requested.
+	user, userErr := ctrl.UserService.GetByEmail(ctx.Request.Context(), ctx.Query("user_email"))
+	if userErr != nil {
+		ctx.JSON(http.StatusNotFound, utils.NewErrorResponse("Not Found", "User not found"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ctrl.SocketAdapter.GetPresence(ctx.Request.Context(), userSessionInfo.Email, user))
+}
+
+// endregion
+
+// region "UpdatePresenceVisibility" handles the request to change who can see the user's presence.
+func (ctrl *userController) UpdatePresenceVisibility(ctx *gin.Context) {
+	var requestBody PresenceVisibilityBody
+
+	// Bind JSON request body to PresenceVisibilityBody struct.
+	if err := ctx.BindJSON(&requestBody); err != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("JSON Bind Error", err.Error()))
+		return
+	}
+
+	// Get user session information.
+	userSessionInfo, sessionErr := utils.GetUserSessionInfo(ctx)
+	if sessionErr != nil {
+		ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Session Error", sessionErr.Error()))
+		return
+	}
+
+	if err := ctrl.UserService.UpdatePresenceVisibilityByMail(ctx.Request.Context(), requestBody.PresenceVisibility, userSessionInfo.Email); err != nil {
+		if errors.Is(err, service.ErrInvalidPresenceVisibility) {
+			ctx.JSON(http.StatusBadRequest, utils.NewErrorResponse("Bad Request", err.Error()))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal Server Error", "Error updating presence visibility"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, utils.NewSuccessResponse("OK", "Presence visibility successfully updated"))
+}
+
+// endregion
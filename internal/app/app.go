@@ -1,47 +1,120 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
+	sessionRedis "github.com/gin-contrib/sessions/redis"
 	"github.com/gin-gonic/gin"
+	"github.com/gomodule/redigo/redis"
 	"github.com/kwa0x2/swiftchat-backend/config"
 	"github.com/kwa0x2/swiftchat-backend/internal/di"
+	"github.com/kwa0x2/swiftchat-backend/internal/jobs"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
+	"github.com/kwa0x2/swiftchat-backend/internal/tracing"
 	"github.com/kwa0x2/swiftchat-backend/routes"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
+	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
 	"github.com/resend/resend-go/v2"
 	"github.com/zishang520/socket.io/socket"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// ShutdownTimeout bounds how long in-flight requests and queries are given to finish once a shutdown signal arrives.
+const ShutdownTimeout = 30 * time.Second
+
 type App struct {
-	Router       *gin.Engine    // Gin router for handling HTTP requests
-	Socket       *socket.Server // Socket.IO server for real-time communication
-	ResendClient *resend.Client // Resend client for sending emails
+	Router        *gin.Engine                  // Gin router for handling HTTP requests
+	Server        *http.Server                 // HTTP server serving the router
+	MetricsServer *http.Server                 // Internal HTTP server serving Prometheus metrics, off the public port
+	Socket        *socket.Server               // Socket.IO server for real-time communication
+	SocketCluster *cluster.RedisAdapterBuilder // Relays socket.io room operations between instances
+	SocketGateway gateway.ISocketGateway       // Gateway used to tell connected sockets about a shutdown
+	PresenceStore cluster.IPresenceStore       // Presence shared by all instances
+	RedisPool     *redis.Pool                  // Redis pool for the socket cluster and presence
+	SessionStore  sessionRedis.Store           // Redis session store
+	ResendClient  *resend.Client               // Resend client for sending emails
+
+	shutdownTracing func(ctx context.Context) error // Flushes and stops the tracer provider
+
+	ctx    context.Context    // Cancelled when shutdown begins, stopping background jobs and long-lived requests
+	cancel context.CancelFunc // Cancels ctx
 }
 
 // region "NewApp" initializes a new App instance and configures the necessary components.
 func NewApp() *App {
-	config.LoadEnv()                                              // Load environment variables and configure services
-	config.PostgreConnection()                                    // Initialize PostgreSQL connection
-	config.InitS3()                                               // Initialize S3 storage
-	router := gin.New()                                           // Create a new Gin engine
-	socketServer := socket.NewServer(nil, nil)                    // Create a new Socket.IO server
+	config.LoadEnv()     // Load environment variables and configure services
+	config.SetupLogger() // Use the structured logger configured by the environment
+
+	// Trace requests, socket events, queries and outbound calls with the configured exporter
+	traceExporter, err := config.TraceExporter(context.Background())
+	if err != nil {
+		panic(err)
+	}
+	shutdownTracing := tracing.Setup(config.TraceServiceName(), traceExporter, false)
+
+	config.PostgreConnection()      // Initialize PostgreSQL connection
+	config.InitS3()                 // Initialize S3 storage
+	router := gin.New()             // Create a new Gin engine
+	redisPool := config.RedisPool() // Initialize the Redis pool shared by socket instances
+
+	// Record query durations and trace queries
+	if err := config.DB.Use(metrics.GormPlugin{}); err != nil {
+		panic(err)
+	}
+	if err := config.DB.Use(tracing.GormPlugin{}); err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Create a new Socket.IO server whose room emits reach sockets on every instance
+	socketCluster := cluster.NewRedisAdapterBuilder(ctx, redisPool, "socket.io")
+	socketOptions := socket.DefaultServerOptions()
+	socketOptions.SetAdapter(socketCluster)
+	socketServer := socket.NewServer(nil, socketOptions)
+
 	resendClient := resend.NewClient(os.Getenv("RESEND_API_KEY")) // Initialize the Resend client with the API key from environment variables
 	store := config.RedisSession()                                // Initialize Redis session store
 
-	// Middleware for sessions and CORS
-	router.Use(sessions.Sessions("connect.sid", store)) // Use session management middleware
+	// Middleware for tracing, request IDs, request metrics, sessions and CORS
+	router.Use(otelgin.Middleware(config.TraceServiceName())) // Start a span per route, continuing incoming traces
+	router.Use(logging.RequestID())                           // Tag requests with an ID and log them
+	router.Use(metrics.GinMiddleware())                       // Record request counts and latency per route
+	router.Use(sessions.Sessions("connect.sid", store))       // Use session management middleware
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},                            // Allow requests from this origin
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"}, // Allowed HTTP methods
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},          // Allowed headers
-		ExposeHeaders:    []string{"Content-Length"},                                   // Exposed headers to the client
-		AllowCredentials: true,                                                         // Allow credentials in requests
+		AllowOrigins:     []string{"http://localhost:3000"},                                                                         // Allow requests from this origin
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "PATCH", "OPTIONS"},                                              // Allowed HTTP methods
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", logging.RequestIDHeader, "traceparent", "tracestate"}, // Allowed headers
+		ExposeHeaders:    []string{"Content-Length", logging.RequestIDHeader},                                                       // Exposed headers to the client
+		AllowCredentials: true,                                                                                                      // Allow credentials in requests
 	}))
 
+	// Metrics are scraped from an internal listener rather than the public router
+	metricsMux := http.NewServeMux()
+	routes.MetricsRoute(metricsMux)
+
 	return &App{ // Return a new App instance with the configured components
-		Router:       router,
-		Socket:       socketServer,
-		ResendClient: resendClient,
+		Router:        router,
+		Server:        &http.Server{Addr: ":9000", Handler: router},
+		MetricsServer: &http.Server{Addr: config.MetricsAddr(), Handler: metricsMux},
+		Socket:        socketServer,
+		SocketCluster: socketCluster,
+		RedisPool:     redisPool,
+		SessionStore:  store,
+		ResendClient:  resendClient,
+		ctx:           ctx,
+		cancel:        cancel,
+
+		shutdownTracing: shutdownTracing,
 	}
 }
 
@@ -49,24 +122,112 @@ func NewApp() *App {
 
 // region "SetupRoutes" initializes the application's routes and associates them with the controllers.
 func (a *App) SetupRoutes() {
-	container := di.NewContainer(a.Socket, a.ResendClient) // Create a new dependency injection container
+	err, sessionStore := sessionRedis.GetRedisStore(a.SessionStore)
+	if err != nil {
+		panic(err)
+	}
+	container := di.NewContainer(a.ctx, a.Socket, a.ResendClient, a.RedisPool, sessionStore.Pool) // Create a new dependency injection container
+	a.SocketGateway = container.SocketGateway
+	a.PresenceStore = container.PresenceStore
 
 	// Setup routes for various controllers
+	routes.HealthRoute(a.Router, container.HealthController)
 	routes.UserRoute(a.Router, container.UserController)
 	routes.AuthRoute(a.Router, container.AuthController)
 	routes.MessageRoute(a.Router, container.MessageController)
 	routes.FriendRoute(a.Router, container.FriendController)
 	routes.RequestRoute(a.Router, container.RequestController)
 	routes.RoomRoute(a.Router, container.RoomController)
+	routes.RoomInviteRoute(a.Router, container.RoomInviteController)
+	routes.ChannelRoute(a.Router, container.ChannelController)
 	routes.FileRoute(a.Router, container.FileController)
+	routes.NotificationRoute(a.Router, container.NotificationController)
 	routes.SetupSocketIO(a.Router, a.Socket, container.SocketAdapter) // Setup Socket.IO routes
+
+	// Permanently remove deleted rooms once their grace period has passed
+	jobs.StartRoomPurge(a.ctx, container.RoomService, jobs.RoomPurgeInterval, jobs.RoomPurgeGracePeriod)
 }
 
 // endregion
 
-// region "Run" starts the HTTP server on the specified port.
+// region "Run" starts the HTTP and metrics servers and shuts them down gracefully on SIGINT or SIGTERM.
 func (a *App) Run() error {
-	return a.Router.Run(":9000") // Start the server on port 9000
+	serverErr := make(chan error, 2)
+	for _, server := range []*http.Server{a.Server, a.MetricsServer} {
+		go func() {
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- err
+			}
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-serverErr:
+		return err // A server could not start or stopped on its own.
+	case sig := <-signals:
+		slog.Info("shutting down", "signal", sig.String())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	return a.Shutdown(ctx)
+}
+
+// endregion
+
+// region "Shutdown" stops the app, giving in-flight work until the context's deadline to finish before closing every connection.
+func (a *App) Shutdown(ctx context.Context) error {
+	// Stop background jobs and end notification streams.
+	a.cancel()
+
+	// Ask sockets to reconnect to another instance, then disconnect them while the database and Redis are still open
+	// so their disconnect handlers can record last-seen times and presence.
+	if a.SocketGateway != nil {
+		a.SocketGateway.NotifyShutdown()
+	}
+	a.Socket.Close(nil)
+
+	// Stop accepting connections and wait for in-flight handlers, and so their transactions, to finish.
+	var errs []error
+	if err := a.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Metrics stay scrapable while the public server drains.
+	if err := a.MetricsServer.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	a.SocketCluster.Close()
+	if a.PresenceStore != nil {
+		a.PresenceStore.Close()
+	}
+
+	if err := config.CloseDatabase(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err, rediStore := sessionRedis.GetRedisStore(a.SessionStore); err != nil {
+		errs = append(errs, err)
+	} else if err := rediStore.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := a.RedisPool.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Flush the spans of everything above.
+	if err := a.shutdownTracing(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
 }
 
 // endregion
@@ -1,29 +1,40 @@
 package di
 
 import (
+	"context"
+	"github.com/gomodule/redigo/redis"
 	"github.com/kwa0x2/swiftchat-backend/config"
 	"github.com/kwa0x2/swiftchat-backend/controller"
 	"github.com/kwa0x2/swiftchat-backend/repository"
 	"github.com/kwa0x2/swiftchat-backend/service"
 	"github.com/kwa0x2/swiftchat-backend/socket/adapter"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
 	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
 	"github.com/resend/resend-go/v2"
 	"github.com/zishang520/socket.io/socket"
 )
 
 type Container struct {
-	UserController    controller.IUserController
-	AuthController    controller.IAuthController
-	RoomController    controller.IRoomController
-	MessageController controller.IMessageController
-	FriendController  controller.IFriendController
-	RequestController controller.IRequestController
-	FileController    controller.IFileController
-	SocketAdapter     adapter.ISocketAdapter
+	UserController         controller.IUserController
+	AuthController         controller.IAuthController
+	RoomController         controller.IRoomController
+	RoomInviteController   controller.IRoomInviteController
+	ChannelController      controller.IChannelController
+	MessageController      controller.IMessageController
+	FriendController       controller.IFriendController
+	RequestController      controller.IRequestController
+	FileController         controller.IFileController
+	NotificationController controller.INotificationController
+	HealthController       controller.IHealthController
+	SocketAdapter          adapter.ISocketAdapter
+	RoomService            service.IRoomService
+	PresenceStore          cluster.IPresenceStore
+	SocketGateway          gateway.ISocketGateway
 }
 
 // region "NewContainer" initializes a new DI container, wiring up all dependencies.
-func NewContainer(socketServer *socket.Server, resendClient *resend.Client) *Container {
+// The context is cancelled when the app starts shutting down, ending long-lived requests such as notification streams.
+func NewContainer(ctx context.Context, socketServer *socket.Server, resendClient *resend.Client, redisPool, sessionPool *redis.Pool) *Container {
 	s3Service := service.NewS3Service()                     // S3 service for file storage
 	resendService := service.NewResendService(resendClient) // Resend service for email handling
 
@@ -33,11 +44,15 @@ func NewContainer(socketServer *socket.Server, resendClient *resend.Client) *Con
 	userRoomRepository := repository.NewUserRoomRepository(config.DB) // User-Room repository for data access
 	userRoomService := service.NewUserRoomService(userRoomRepository) // User-Room service for business logic
 
-	roomRepository := repository.NewRoomRepository(config.DB)              // Room repository for data access
-	roomService := service.NewRoomService(roomRepository, userRoomService) // Room service for business logic
+	roomRepository := repository.NewRoomRepository(config.DB)                         // Room repository for data access
+	roomService := service.NewRoomService(roomRepository, userRoomService, s3Service) // Room service for business logic
 
-	messageRepository := repository.NewMessageRepository(config.DB)             // Message repository for data access
-	messageService := service.NewMessageService(messageRepository, roomService) // Message service for business logic
+	roomInviteRepository := repository.NewRoomInviteRepository(config.DB)                                 // Room invite repository for data access
+	roomInviteService := service.NewRoomInviteService(roomInviteRepository, roomService, userRoomService) // Room invite service for business logic
+
+	messageRepository := repository.NewMessageRepository(config.DB)                                                       // Message repository for data access
+	hiddenMessageRepository := repository.NewHiddenMessageRepository(config.DB)                                           // Hidden message repository for "delete for me"
+	messageService := service.NewMessageService(messageRepository, hiddenMessageRepository, roomService, userRoomService) // Message service for business logic
 
 	friendRepository := repository.NewFriendRepository(config.DB) // Friend repository for data access
 	friendService := service.NewFriendService(friendRepository)   // Friend service for business logic
@@ -45,19 +60,38 @@ func NewContainer(socketServer *socket.Server, resendClient *resend.Client) *Con
 	requestRepository := repository.NewRequestRepository(config.DB)                            // Request repository for data access
 	requestService := service.NewRequestService(requestRepository, friendService, userService) // Request service for business logic
 
-	socketGateway := gateway.NewSocketGateway(socketServer, "/chat")                                        // Initialize the socket gateway for handling socket connections
-	socketAdapter := adapter.NewSocketAdapter(socketGateway, messageService, friendService, requestService) // Socket adapter for emitting events
+	// Dependencies checked by the readiness probe
+	healthService := service.NewHealthService(map[string]service.HealthCheck{
+		"postgres":       service.PostgresHealthCheck(config.DB),
+		"redis":          service.RedisHealthCheck(sessionPool),
+		"object_storage": service.S3HealthCheck(config.S3Client, config.GetS3BucketName()),
+	}, service.HealthCheckTimeout)
+
+	presenceStore := cluster.NewPresenceStore(ctx, redisPool) // Presence shared by all instances through Redis
+
+	notificationStream := cluster.NewNotificationStream(redisPool) // Per-user notification streams served over SSE
+
+	eventLog := cluster.NewEventLog(redisPool)                                                                                                                                                                                            // Numbered room events kept for replay after reconnects
+	socketGateway := gateway.NewSocketGateway(socketServer, "/chat", eventLog, notificationStream)                                                                                                                                        // Initialize the socket gateway for handling socket connections
+	socketAdapter := adapter.NewSocketAdapter(ctx, socketGateway, messageService, friendService, requestService, userRoomService, userService, presenceStore, eventLog, config.SocketRateLimits(), config.SocketMaxRateLimitViolations()) // Socket adapter for emitting events
 
 	// Return a new Container with all initialized controllers and the socket adapter
 	return &Container{
-		UserController:    controller.NewUserController(userService, friendService, s3Service, socketAdapter),
-		AuthController:    controller.NewAuthController(userService),
-		RoomController:    controller.NewRoomController(roomService, userRoomService, userService, friendService),
-		MessageController: controller.NewMessageController(messageService),
-		FriendController:  controller.NewFriendController(friendService, socketGateway),
-		RequestController: controller.NewRequestController(requestService, friendService, userService, socketGateway, resendService),
-		FileController:    controller.NewFileController(s3Service),
-		SocketAdapter:     socketAdapter,
+		UserController:         controller.NewUserController(userService, friendService, s3Service, socketAdapter),
+		AuthController:         controller.NewAuthController(userService),
+		RoomController:         controller.NewRoomController(roomService, userRoomService, userService, friendService, socketGateway, socketAdapter),
+		RoomInviteController:   controller.NewRoomInviteController(roomInviteService, roomService, socketGateway),
+		ChannelController:      controller.NewChannelController(roomService, socketGateway),
+		MessageController:      controller.NewMessageController(messageService, socketAdapter),
+		FriendController:       controller.NewFriendController(friendService, socketGateway),
+		RequestController:      controller.NewRequestController(requestService, friendService, userService, socketGateway, resendService),
+		FileController:         controller.NewFileController(s3Service),
+		NotificationController: controller.NewNotificationController(ctx, notificationStream),
+		HealthController:       controller.NewHealthController(ctx, healthService),
+		RoomService:            roomService,
+		PresenceStore:          presenceStore,
+		SocketGateway:          socketGateway,
+		SocketAdapter:          socketAdapter,
 	}
 }
 
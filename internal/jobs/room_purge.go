@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/service"
+	"time"
+)
+
+const (
+	RoomPurgeInterval    = time.Hour          // How often deleted rooms are checked for purging.
+	RoomPurgeGracePeriod = 7 * 24 * time.Hour // How long a deleted room is kept before it is purged for good.
+)
+
+// region "StartRoomPurge" periodically purges rooms whose grace period has passed until the context is cancelled.
+func StartRoomPurge(ctx context.Context, roomService service.IRoomService, interval, gracePeriod time.Duration) {
+	// Every log of the job says which job wrote it.
+	ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("job", "room_purge"))
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := roomService.PurgeDeletedRooms(ctx, gracePeriod); err != nil {
+					logging.FromContext(ctx).Error("room purge failed", "error", err)
+				}
+			}
+		}
+	}()
+}
+
+// endregion
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+	"log/slog"
+	"time"
+)
+
+// GormLogger writes GORM's logs through the logger of the query's context, so SQL logs carry request IDs.
+type GormLogger struct {
+	Level         gormLogger.LogLevel // Silent, Error, Warn (slow queries) or Info (every query)
+	SlowThreshold time.Duration       // Queries taking longer are logged as warnings
+}
+
+func NewGormLogger(level gormLogger.LogLevel, slowThreshold time.Duration) gormLogger.Interface {
+	return &GormLogger{
+		Level:         level,
+		SlowThreshold: slowThreshold,
+	}
+}
+
+// region "LogMode" returns a copy of the logger using the given level, as used by db.Debug()
+func (l *GormLogger) LogMode(level gormLogger.LogLevel) gormLogger.Interface {
+	copied := *l
+	copied.Level = level
+	return &copied
+}
+
+// endregion
+
+func (l *GormLogger) Info(ctx context.Context, message string, args ...interface{}) {
+	if l.Level >= gormLogger.Info {
+		FromContext(ctx).InfoContext(ctx, fmt.Sprintf(message, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, message string, args ...interface{}) {
+	if l.Level >= gormLogger.Warn {
+		FromContext(ctx).WarnContext(ctx, fmt.Sprintf(message, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, message string, args ...interface{}) {
+	if l.Level >= gormLogger.Error {
+		FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(message, args...))
+	}
+}
+
+// region "Trace" logs a finished query: failures at Error, slow ones at Warn and the rest at Info
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.Level <= gormLogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	logger := FromContext(ctx)
+	switch {
+	case err != nil && l.Level >= gormLogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		query, rows := fc()
+		logger.ErrorContext(ctx, "query failed", slog.String("sql", query), slog.Int64("rows", rows), slog.Duration("duration", elapsed), slog.Any("error", err))
+	case l.SlowThreshold > 0 && elapsed > l.SlowThreshold && l.Level >= gormLogger.Warn:
+		query, rows := fc()
+		logger.WarnContext(ctx, "slow query", slog.String("sql", query), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	case l.Level >= gormLogger.Info:
+		query, rows := fc()
+		logger.InfoContext(ctx, "query", slog.String("sql", query), slog.Int64("rows", rows), slog.Duration("duration", elapsed))
+	}
+}
+
+// endregion
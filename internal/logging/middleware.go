@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"time"
+)
+
+// RequestIDHeader carries the ID of a request from the client or a proxy, and back in the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-provided request IDs so they cannot bloat the logs.
+const maxRequestIDLength = 128
+
+// region "RequestID" tags every request with an ID, reusing the incoming X-Request-ID when there is one,
+// attaches a logger carrying it and the trace ID to the request context and logs the request once it is handled
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestId := ctx.GetHeader(RequestIDHeader)
+		if requestId == "" || len(requestId) > maxRequestIDLength {
+			requestId = uuid.NewString()
+		}
+		ctx.Header(RequestIDHeader, requestId)
+
+		logger := slog.Default().With("request_id", requestId)
+		if spanContext := trace.SpanContextFromContext(ctx.Request.Context()); spanContext.HasTraceID() {
+			logger = logger.With("trace_id", spanContext.TraceID().String())
+		}
+		ctx.Request = ctx.Request.WithContext(WithLogger(ctx.Request.Context(), logger))
+
+		start := time.Now()
+		ctx.Next()
+
+		level := slog.LevelInfo
+		if ctx.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(ctx.Request.Context(), level, "request handled",
+			slog.String("method", ctx.Request.Method),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.String("route", ctx.FullPath()),
+			slog.Int("status", ctx.Writer.Status()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+}
+
+// endregion
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+const gormStartKey = "metrics:start"
+
+// GormPlugin records the duration of every query run through GORM.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "metrics"
+}
+
+// region "Initialize" registers timing callbacks around each kind of GORM operation
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []error{
+		callback.Create().Before("*").Register("metrics:before_create", startTimer),
+		callback.Create().After("*").Register("metrics:after_create", observeQuery("create")),
+		callback.Query().Before("*").Register("metrics:before_query", startTimer),
+		callback.Query().After("*").Register("metrics:after_query", observeQuery("query")),
+		callback.Update().Before("*").Register("metrics:before_update", startTimer),
+		callback.Update().After("*").Register("metrics:after_update", observeQuery("update")),
+		callback.Delete().Before("*").Register("metrics:before_delete", startTimer),
+		callback.Delete().After("*").Register("metrics:after_delete", observeQuery("delete")),
+		callback.Row().Before("*").Register("metrics:before_row", startTimer),
+		callback.Row().After("*").Register("metrics:after_row", observeQuery("row")),
+		callback.Raw().Before("*").Register("metrics:before_raw", startTimer),
+		callback.Raw().After("*").Register("metrics:after_raw", observeQuery("raw")),
+	}
+	return errors.Join(registrations...)
+}
+
+// endregion
+
+// region "startTimer" remembers when a statement started
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(gormStartKey, time.Now())
+}
+
+// endregion
+
+// region "observeQuery" records how long a statement of the given operation took
+func observeQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(gormStartKey)
+		if !ok {
+			return
+		}
+		start, ok := value.(time.Time)
+		if !ok {
+			return
+		}
+
+		// Not finding a record is an expected result rather than a failed query.
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+
+		DBQueryDuration.WithLabelValues(operation, db.Statement.Table, Outcome(err)).Observe(time.Since(start).Seconds())
+	}
+}
+
+// endregion
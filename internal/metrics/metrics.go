@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+var (
+	// HTTPRequests counts handled HTTP requests per route template, method and status code.
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Handled HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes how long HTTP handlers take per route template and method.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to handle HTTP requests.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// SocketConnections is the number of sockets connected to this instance.
+	SocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "socket_connections",
+		Help: "Sockets connected to this instance.",
+	})
+
+	// SocketEvents counts socket events received per event type.
+	SocketEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "socket_events_total",
+		Help: "Socket events handled.",
+	}, []string{"event"})
+
+	// SocketEventErrors counts socket events that were answered with an error, including throttled ones.
+	SocketEventErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "socket_event_errors_total",
+		Help: "Socket events answered with an error.",
+	}, []string{"event"})
+
+	// MessageSendDuration observes how long storing and emitting a sent message takes.
+	MessageSendDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "message_send_duration_seconds",
+		Help:    "Time taken to store and emit a sent message.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	// DBQueryDuration observes GORM query durations per operation and table.
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Time taken by database queries.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table", "outcome"})
+
+	// EmailSends counts emails sent through Resend per outcome.
+	EmailSends = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "email_sends_total",
+		Help: "Emails sent through Resend.",
+	}, []string{"outcome"})
+
+	// S3Operations counts object storage calls per operation and outcome.
+	S3Operations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "s3_operations_total",
+		Help: "Object storage calls.",
+	}, []string{"operation", "outcome"})
+)
+
+// region "Outcome" returns the outcome label of an operation that returned the given error
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// endregion
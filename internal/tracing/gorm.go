@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin traces every query run through GORM as a child of the span in the statement's context.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// region "Initialize" registers callbacks starting and ending a span around each kind of GORM operation
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []error{
+		callback.Create().Before("*").Register("tracing:before_create", startSpan("create")),
+		callback.Create().After("*").Register("tracing:after_create", endSpan),
+		callback.Query().Before("*").Register("tracing:before_query", startSpan("query")),
+		callback.Query().After("*").Register("tracing:after_query", endSpan),
+		callback.Update().Before("*").Register("tracing:before_update", startSpan("update")),
+		callback.Update().After("*").Register("tracing:after_update", endSpan),
+		callback.Delete().Before("*").Register("tracing:before_delete", startSpan("delete")),
+		callback.Delete().After("*").Register("tracing:after_delete", endSpan),
+		callback.Row().Before("*").Register("tracing:before_row", startSpan("row")),
+		callback.Row().After("*").Register("tracing:after_row", endSpan),
+		callback.Raw().Before("*").Register("tracing:before_raw", startSpan("raw")),
+		callback.Raw().After("*").Register("tracing:after_raw", endSpan),
+	}
+	return errors.Join(registrations...)
+}
+
+// endregion
+
+// region "startSpan" starts the span of a statement of the given operation
+func startSpan(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		_, span := otel.Tracer(InstrumentationName).Start(db.Statement.Context, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "postgresql"),
+				attribute.String("db.operation", operation),
+			))
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+// endregion
+
+// region "endSpan" records the statement's SQL, table and outcome on its span and ends it
+func endSpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+
+	span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+
+	// Not finding a record is an expected result rather than a failed query.
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
+
+// endregion
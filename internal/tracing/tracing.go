@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName names the tracer used for the app's own spans.
+const InstrumentationName = "github.com/kwa0x2/swiftchat-backend"
+
+// region "Setup" installs a global tracer provider sending spans to the exporter, and W3C trace context propagation.
+// A nil exporter disables tracing. The returned function flushes and stops the provider.
+func Setup(serviceName string, exporter sdktrace.SpanExporter, syncExport bool) func(ctx context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if exporter == nil {
+		return func(context.Context) error { return nil }
+	}
+
+	// Synchronous export keeps spans visible as soon as they end, which tests rely on.
+	processor := sdktrace.NewBatchSpanProcessor(exporter)
+	if syncExport {
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown
+}
+
+// endregion
+
+// region "Start" starts a span of the app as a child of the span in the context
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// endregion
+
+// region "End" ends a span, marking it failed when err is not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endregion
+
+// region "StartLinked" starts a span in a new trace linked to the span in the context, for work that outlives the request which started it
+func StartLinked(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(ctx)),
+		trace.WithAttributes(attributes...),
+	)
+}
+
+// endregion
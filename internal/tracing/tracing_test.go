@@ -0,0 +1,138 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// setupTestTracing installs a tracer provider exporting to memory as soon as spans end, shut down with the test.
+func setupTestTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := Setup("swiftchat-test", exporter, true)
+	t.Cleanup(func() { shutdown(context.Background()) })
+	return exporter
+}
+
+// findSpan returns the exported span with the given name, failing the test when there is none.
+func findSpan(t *testing.T, exporter *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	t.Helper()
+
+	var names []string
+	for _, span := range exporter.GetSpans() {
+		if span.Name == name {
+			return span
+		}
+		names = append(names, span.Name)
+	}
+	t.Fatalf("no span named %q, got %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+// attributeValue returns the value of the span attribute with the given key, or an empty value.
+func attributeValue(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestRouteSpansContinueIncomingTraces(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(otelgin.Middleware("swiftchat-test"))
+	router.GET("/api/v1/room/:id", func(ctx *gin.Context) {
+		_, span := Start(ctx.Request.Context(), "load room")
+		End(span, nil)
+		ctx.Status(http.StatusOK)
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/room/42", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	routeSpan := findSpan(t, exporter, "/api/v1/room/:id")
+	if routeSpan.SpanKind != trace.SpanKindServer {
+		t.Errorf("route span kind is %v, want server", routeSpan.SpanKind)
+	}
+	if got := routeSpan.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("route span is in trace %s, want the incoming trace", got)
+	}
+	if got := routeSpan.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("route span parent is %s, want the incoming span", got)
+	}
+
+	childSpan := findSpan(t, exporter, "load room")
+	if childSpan.Parent.SpanID() != routeSpan.SpanContext.SpanID() {
+		t.Error("span started by the handler is not a child of the route span")
+	}
+}
+
+func TestGormPluginTracesQueries(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	// A dry run builds statements and runs the callbacks without a database.
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := Start(context.Background(), "request")
+	var rooms []struct{ RoomID string }
+	db.WithContext(ctx).Table("ROOM").Where("room_id = ?", "42").Find(&rooms)
+	parent.End()
+
+	querySpan := findSpan(t, exporter, "gorm.query")
+	if querySpan.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("query span is not a child of the span in the statement's context")
+	}
+	if querySpan.SpanKind != trace.SpanKindClient {
+		t.Errorf("query span kind is %v, want client", querySpan.SpanKind)
+	}
+	if got := attributeValue(querySpan, "db.sql.table").AsString(); got != "ROOM" {
+		t.Errorf("query span table is %q, want ROOM", got)
+	}
+	if got := attributeValue(querySpan, "db.statement").AsString(); got != `SELECT * FROM "ROOM" WHERE room_id = $1` {
+		t.Errorf("query span statement is %q", got)
+	}
+}
+
+func TestSocketEventSpansLinkToTheHandshake(t *testing.T) {
+	exporter := setupTestTracing(t)
+
+	// Socket events run long after the handshake request ended, so they start their own trace linked to it.
+	handshakeCtx, handshake := Start(context.Background(), "GET /socket.io/")
+	handshake.End()
+
+	_, event := StartLinked(handshakeCtx, "socket sendMessage", attribute.String("socket.event", "sendMessage"))
+	End(event, nil)
+
+	eventSpan := findSpan(t, exporter, "socket sendMessage")
+	if eventSpan.Parent.IsValid() {
+		t.Error("socket event span has a parent, want a new root")
+	}
+	if eventSpan.SpanContext.TraceID() == handshake.SpanContext().TraceID() {
+		t.Error("socket event span is in the handshake's trace, want a new one")
+	}
+	if len(eventSpan.Links) != 1 || eventSpan.Links[0].SpanContext.SpanID() != handshake.SpanContext().SpanID() {
+		t.Errorf("socket event span links %v, want the handshake span", eventSpan.Links)
+	}
+}
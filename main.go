@@ -3,7 +3,8 @@ package main
 import (
 	"encoding/gob"
 	"github.com/kwa0x2/swiftchat-backend/internal/app"
-	"log"
+	"log/slog"
+	"os"
 	"time"
 )
 
@@ -16,6 +17,7 @@ func main() {
 	application.SetupRoutes()
 
 	if err := application.Run(); err != nil {
-		log.Fatal("failed to run app: ", err)
+		slog.Error("failed to run app", "error", err)
+		os.Exit(1)
 	}
 }
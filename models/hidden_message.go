@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type HiddenMessage struct {
+	UserID    string    `json:"user_id" gorm:"primaryKey;not null"`
+	MessageID uuid.UUID `json:"message_id" gorm:"primaryKey;not null;type:uuid"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;column:createdAt;default:CURRENT_TIMESTAMP"`
+}
+
+func (HiddenMessage) TableName() string {
+	return "HIDDEN_MESSAGE"
+}
@@ -16,6 +16,7 @@ type Message struct {
 	MessageReadStatus types.ReadStatus  `json:"message_read_status" gorm:"type:read_status;not null;default:unread"`
 	MessageType       types.MessageType `json:"message_type" gorm:"type:message_type;not null;default:text"`
 	MessageStarred    bool              `json:"message_starred" gorm:"not null;default:false"`
+	ClientMessageID   *string           `json:"client_message_id,omitempty" gorm:"column:client_message_id"` // Optional sender-generated ID that makes retried sends idempotent
 
 	CreatedAt time.Time      `json:"createdAt" gorm:"not null;column:createdAt;default:CURRENT_TIMESTAMP"`
 	UpdatedAt time.Time      `json:"updatedAt" gorm:"not null;column:updatedAt;default:CURRENT_TIMESTAMP"`
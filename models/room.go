@@ -8,8 +8,8 @@ import (
 )
 
 type Room struct {
-	RoomID        uuid.UUID      `json:"room_id" gorm:"not null;type:uuid;default:gen_random_uuid()"`
-	CreatedUserID string         `json:"created_user_id" gorm:"not null;primaryKey"`
+	RoomID        uuid.UUID      `json:"room_id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	CreatedUserID string         `json:"created_user_id" gorm:"not null"`
 	MessageCount  int64          `json:"message_count"`
 	LastMessage   string         `json:"last_message"`
 	RoomType      types.RoomType `json:"room_type" gorm:"not null;type:room_type;default:private"`
@@ -17,6 +17,8 @@ type Room struct {
 	UpdatedAt     time.Time      `json:"updatedAt" gorm:"not null;column:updatedAt;default:CURRENT_TIMESTAMP"`
 	DeletedAt     gorm.DeletedAt `json:"deletedAt" gorm:"column:deletedAt"`
 	LastMessageID uuid.UUID      `json:"message_id" gorm:"type:uuid"`
+	SlowModeSecs  int            `json:"slow_mode_seconds" gorm:"column:slow_mode_seconds;not null;default:0"` // Minimum seconds between messages of a member, 0 when disabled
+	PrivateKey    *string        `json:"-" gorm:"column:private_key;uniqueIndex"`                              // Canonical pair of member IDs for private rooms, nil otherwise
 
 	UserRoom UserRoom `json:"user_room" gorm:"foreignKey:RoomID;references:RoomID"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type RoomInvite struct {
+	InviteID      uuid.UUID      `json:"invite_id" gorm:"primaryKey;not null;type:uuid;default:gen_random_uuid()"`
+	RoomID        uuid.UUID      `json:"room_id" gorm:"not null;type:uuid"`
+	Token         string         `json:"token" gorm:"not null;unique"`
+	CreatedUserID string         `json:"created_user_id" gorm:"not null"`
+	ExpiresAt     *time.Time     `json:"expiresAt" gorm:"column:expiresAt"`
+	MaxUses       *int           `json:"max_uses"`
+	UseCount      int            `json:"use_count" gorm:"not null;default:0"`
+	RevokedUserID *string        `json:"revoked_user_id"`
+	RevokedAt     *time.Time     `json:"revokedAt" gorm:"column:revokedAt"`
+	CreatedAt     time.Time      `json:"createdAt" gorm:"not null;column:createdAt;default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time      `json:"updatedAt" gorm:"not null;column:updatedAt;default:CURRENT_TIMESTAMP"`
+	DeletedAt     gorm.DeletedAt `json:"deletedAt" gorm:"column:deletedAt"` // Set when the room was deleted
+
+	Uses []RoomInviteUse `json:"uses" gorm:"foreignKey:InviteID;references:InviteID"`
+}
+
+func (RoomInvite) TableName() string {
+	return "ROOM_INVITE"
+}
+
+type RoomInviteUse struct {
+	InviteID  uuid.UUID `json:"invite_id" gorm:"primaryKey;not null;type:uuid"`
+	UserID    string    `json:"user_id" gorm:"primaryKey;not null"`
+	CreatedAt time.Time `json:"createdAt" gorm:"not null;column:createdAt;default:CURRENT_TIMESTAMP"`
+}
+
+func (RoomInviteUse) TableName() string {
+	return "ROOM_INVITE_USE"
+}
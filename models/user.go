@@ -1,6 +1,7 @@
 package models
 
 import (
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
 	"time"
 )
@@ -15,6 +16,14 @@ type User struct {
 	UpdatedAt time.Time      `json:"updatedAt" gorm:"not null;column:updatedAt;default:CURRENT_TIMESTAMP"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt" gorm:"column:deletedAt"`
 
+	LastSeenAt         *time.Time               `json:"lastSeenAt" gorm:"column:lastSeenAt"`                                           // When the user's last socket disconnected
+	PresenceVisibility types.PresenceVisibility `json:"presence_visibility" gorm:"type:presence_visibility;not null;default:everyone"` // Who can see the user's online status and last-seen time
+
+	Status          types.UserStatus `json:"status" gorm:"type:user_status;not null;default:available"` // Availability chosen by the user
+	StatusText      *string          `json:"status_text"`                                               // Optional custom status message
+	StatusEmoji     *string          `json:"status_emoji"`                                              // Optional emoji shown next to the status
+	StatusExpiresAt *time.Time       `json:"statusExpiresAt" gorm:"column:statusExpiresAt"`             // When the status resets to available, nil to keep it
+
 	Friend *Friend `json:"friend" gorm:"foreignKey:UserMail;references:UserEmail"`
 }
 
@@ -2,6 +2,7 @@ package models
 
 import (
 	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
 	"time"
 )
@@ -9,9 +10,17 @@ import (
 type UserRoom struct {
 	UserID    string         `json:"user_id" gorm:"primaryKey;not null"`
 	RoomID    uuid.UUID      `json:"room_id" gorm:"primaryKey;not null;type:uuid"`
+	RoomRole  types.RoomRole `json:"room_role" gorm:"type:room_role;not null;default:member"`
 	CreatedAt time.Time      `json:"createdAt" gorm:"not null;column:createdAt;default:CURRENT_TIMESTAMP"`
 	UpdatedAt time.Time      `json:"updatedAt" gorm:"not null;column:updatedAt;default:CURRENT_TIMESTAMP"`
 	DeletedAt gorm.DeletedAt `json:"deletedAt" gorm:"column:deletedAt"`
+	ClearedAt *time.Time     `json:"clearedAt" gorm:"column:clearedAt"` // Messages up to this time are hidden from the user's history
+	HiddenAt  *time.Time     `json:"hiddenAt" gorm:"column:hiddenAt"`   // The room is left out of the user's chat list until a newer message arrives
+	BannedAt  *time.Time     `json:"bannedAt" gorm:"column:bannedAt"`   // Set when the user was banned, blocking them from rejoining
+
+	MutedUntil  *time.Time `json:"mutedUntil" gorm:"column:mutedUntil"` // Notifications are muted for the user until this time
+	Archived    bool       `json:"archived" gorm:"not null;default:false"`
+	PinnedOrder *int       `json:"pinned_order"` // Position among the user's pinned rooms, nil when not pinned
 
 	User User  `json:"user" gorm:"foreignKey:UserID;references:UserID"`
 	Room *Room `json:"room" gorm:"foreignKey:RoomID;references:RoomID"`
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/kwa0x2/swiftchat-backend/models"
@@ -9,15 +10,16 @@ import (
 )
 
 type IFriendRepository interface {
-	Create(tx *gorm.DB, friend *models.Friend) error
-	Update(tx *gorm.DB, whereFriend *models.Friend, updates *models.Friend) error
-	UpdateFriendStatusByMail(tx *gorm.DB, userEmail, userEmail2 string, friendStatus types.FriendStatus) error
-	Delete(UserEmail, UserEmail2 string) error
-	GetFriends(userEmail string, isUnFriendStatusAllow bool) ([]*models.Friend, error)
-	GetSpecificFriend(userEmail, userEmail2 string) (*models.Friend, error)
-	GetBlockedUsers(userEmail string) ([]*models.Friend, error)
-	Block(userEmail, userEmail2 string) (string, error)
-	IsBlocked(userMail, otherUserMail string) (bool, error)
+	Create(ctx context.Context, tx *gorm.DB, friend *models.Friend) error
+	Update(ctx context.Context, tx *gorm.DB, whereFriend *models.Friend, updates *models.Friend) error
+	UpdateFriendStatusByMail(ctx context.Context, tx *gorm.DB, userEmail, userEmail2 string, friendStatus types.FriendStatus) error
+	Delete(ctx context.Context, UserEmail, UserEmail2 string) error
+	GetFriends(ctx context.Context, userEmail string, isUnFriendStatusAllow bool) ([]*models.Friend, error)
+	GetSpecificFriend(ctx context.Context, userEmail, userEmail2 string) (*models.Friend, error)
+	GetFriendEmailsAmong(ctx context.Context, userEmail string, candidateEmails []string) ([]string, error)
+	GetBlockedUsers(ctx context.Context, userEmail string) ([]*models.Friend, error)
+	Block(ctx context.Context, userEmail, userEmail2 string) (string, error)
+	IsBlocked(ctx context.Context, userMail, otherUserMail string) (bool, error)
 }
 
 type friendRepository struct {
@@ -31,8 +33,8 @@ func NewFriendRepository(db *gorm.DB) IFriendRepository {
 }
 
 // region "Create" adds a new friend to the database
-func (r *friendRepository) Create(tx *gorm.DB, friend *models.Friend) error {
-	db := r.DB // Use the repository's DB connection
+func (r *friendRepository) Create(ctx context.Context, tx *gorm.DB, friend *models.Friend) error {
+	db := r.DB.WithContext(ctx) // Use the repository's DB connection
 	if tx != nil {
 		db = tx // If a transaction is provided, use it
 	}
@@ -46,8 +48,8 @@ func (r *friendRepository) Create(tx *gorm.DB, friend *models.Friend) error {
 // endregion
 
 // region "Update" modifies the fields of a friend in the database based on specified conditions
-func (r *friendRepository) Update(tx *gorm.DB, whereFriend *models.Friend, updates *models.Friend) error {
-	db := r.DB
+func (r *friendRepository) Update(ctx context.Context, tx *gorm.DB, whereFriend *models.Friend, updates *models.Friend) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx
 	}
@@ -68,8 +70,8 @@ func (r *friendRepository) Update(tx *gorm.DB, whereFriend *models.Friend, updat
 // endregion
 
 // region "UpdateFriendStatusByMail" updates the deletedAt field and friendStatus for given user emails
-func (r *friendRepository) UpdateFriendStatusByMail(tx *gorm.DB, userEmail, userEmail2 string, friendStatus types.FriendStatus) error {
-	db := r.DB
+func (r *friendRepository) UpdateFriendStatusByMail(ctx context.Context, tx *gorm.DB, userEmail, userEmail2 string, friendStatus types.FriendStatus) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx
 	}
@@ -95,8 +97,8 @@ func (r *friendRepository) UpdateFriendStatusByMail(tx *gorm.DB, userEmail, user
 // endregion
 
 // region "Delete" removes a friend relationship from the database
-func (r *friendRepository) Delete(UserEmail, UserEmail2 string) error {
-	if err := r.DB.
+func (r *friendRepository) Delete(ctx context.Context, UserEmail, UserEmail2 string) error {
+	if err := r.DB.WithContext(ctx).
 		Where("(user_mail = ? AND user_mail2 = ?) OR (user_mail = ? AND user_mail2 = ?)",
 			UserEmail,
 			UserEmail2,
@@ -112,7 +114,7 @@ func (r *friendRepository) Delete(UserEmail, UserEmail2 string) error {
 // endregion
 
 // region "GetFriends" retrieves a list of friends based on user email
-func (r *friendRepository) GetFriends(userEmail string, isUnFriendStatusAllow bool) ([]*models.Friend, error) {
+func (r *friendRepository) GetFriends(ctx context.Context, userEmail string, isUnFriendStatusAllow bool) ([]*models.Friend, error) {
 	var friends []*models.Friend
 
 	query := r.DB
@@ -138,10 +140,10 @@ func (r *friendRepository) GetFriends(userEmail string, isUnFriendStatusAllow bo
 // endregion
 
 // region "GetSpecificFriend" retrieves a specific friend relationship based on user emails
-func (r *friendRepository) GetSpecificFriend(userEmail, userEmail2 string) (*models.Friend, error) {
+func (r *friendRepository) GetSpecificFriend(ctx context.Context, userEmail, userEmail2 string) (*models.Friend, error) {
 	var friend models.Friend
 
-	if err := r.DB.Unscoped().
+	if err := r.DB.WithContext(ctx).Unscoped().
 		Select("friend_status, CASE WHEN user_mail = ? THEN user_mail2 ELSE user_mail END as user_mail", userEmail).
 		Where("(user_mail = ? AND user_mail2 = ?)", userEmail, userEmail2).
 		Or("(user_mail2 = ? AND user_mail = ?)", userEmail, userEmail2).
@@ -157,11 +159,34 @@ func (r *friendRepository) GetSpecificFriend(userEmail, userEmail2 string) (*mod
 
 // endregion
 
+// region "GetFriendEmailsAmong" retrieves the emails, out of the given candidates, of the users the user is friends with
+func (r *friendRepository) GetFriendEmailsAmong(ctx context.Context, userEmail string, candidateEmails []string) ([]string, error) {
+	var friendEmails []string
+
+	if len(candidateEmails) == 0 {
+		return friendEmails, nil
+	}
+
+	if err := r.DB.WithContext(ctx).
+		Model(&models.Friend{}).
+		Select("CASE WHEN user_mail = ? THEN user_mail2 ELSE user_mail END", userEmail).
+		Where("friend_status = ?", types.Friend).
+		Where("(user_mail = ? AND user_mail2 IN ?) OR (user_mail2 = ? AND user_mail IN ?)",
+			userEmail, candidateEmails, userEmail, candidateEmails).
+		Scan(&friendEmails).Error; err != nil {
+		return nil, err
+	}
+
+	return friendEmails, nil
+}
+
+// endregion
+
 // region "GetBlockedUsers" retrieves a list of blocked users for a given email
-func (r *friendRepository) GetBlockedUsers(userEmail string) ([]*models.Friend, error) {
+func (r *friendRepository) GetBlockedUsers(ctx context.Context, userEmail string) ([]*models.Friend, error) {
 	var friends []*models.Friend
 
-	if err := r.DB.
+	if err := r.DB.WithContext(ctx).
 		Preload("User").
 		Select("CASE WHEN user_mail = ? THEN user_mail2 ELSE user_mail END as user_mail", userEmail).
 		Where("user_mail = ? AND friend_status = ?", userEmail, "block_first_second").
@@ -176,11 +201,11 @@ func (r *friendRepository) GetBlockedUsers(userEmail string) ([]*models.Friend,
 // endregion
 
 // region "Block" updates the status of a friendship to blocked
-func (r *friendRepository) Block(userEmail, userEmail2 string) (string, error) {
+func (r *friendRepository) Block(ctx context.Context, userEmail, userEmail2 string) (string, error) {
 	var existingFriend models.Friend
 
 	// Check if the friendship exists
-	if err := r.DB.
+	if err := r.DB.WithContext(ctx).
 		Where("(user_mail = ? AND user_mail2 = ?) OR (user_mail = ? AND user_mail2 = ?)", userEmail, userEmail2, userEmail2, userEmail).
 		First(&existingFriend).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -199,7 +224,7 @@ func (r *friendRepository) Block(userEmail, userEmail2 string) (string, error) {
 		}
 	}
 
-	if err := r.DB.Save(&existingFriend).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Save(&existingFriend).Error; err != nil {
 		return "", err
 	}
 
@@ -209,10 +234,10 @@ func (r *friendRepository) Block(userEmail, userEmail2 string) (string, error) {
 // endregion
 
 // region "IsBlocked" checks if a user is blocked by another user
-func (r *friendRepository) IsBlocked(userEmail, userEmail2 string) (bool, error) {
+func (r *friendRepository) IsBlocked(ctx context.Context, userEmail, userEmail2 string) (bool, error) {
 	var count int64
 
-	if err := r.DB.Model(&models.Friend{}).
+	if err := r.DB.WithContext(ctx).Model(&models.Friend{}).
 		Where("(user_mail = ? OR user_mail2 = ?) AND (user_mail = ? OR user_mail2 = ?)",
 							userEmail, userEmail, userEmail2, userEmail2).
 		Where("friend_status != ?", "friend"). // kontrol lazim unfriend durumu
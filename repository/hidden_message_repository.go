@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IHiddenMessageRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, hiddenMessage *models.HiddenMessage) error
+}
+
+type hiddenMessageRepository struct {
+	DB *gorm.DB
+}
+
+func NewHiddenMessageRepository(db *gorm.DB) IHiddenMessageRepository {
+	return &hiddenMessageRepository{
+		DB: db,
+	}
+}
+
+// region "Create" hides a message for a single user, ignoring duplicates
+func (r *hiddenMessageRepository) Create(ctx context.Context, tx *gorm.DB, hiddenMessage *models.HiddenMessage) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	// Hiding an already hidden message is not an error
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(hiddenMessage).Error
+}
+
+// endregion
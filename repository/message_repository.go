@@ -1,18 +1,24 @@
 package repository
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type IMessageRepository interface {
-	Create(tx *gorm.DB, message *models.Message) (*models.Message, error)
-	UpdateExceptUpdatedAt(whereMessage *models.Message, updateMessage *models.Message, isUnscoped bool) error
-	Delete(whereMessage *models.Message) error
-	ReadMessageByRoomId(connectedUserID, roomId string, messageId *string) error
-	GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*models.Message, error)
+	Create(ctx context.Context, tx *gorm.DB, message *models.Message) (*models.Message, error)
+	CreateIfAbsent(ctx context.Context, tx *gorm.DB, message *models.Message) (bool, error)
+	UpdateExceptUpdatedAt(ctx context.Context, whereMessage *models.Message, updateMessage *models.Message, isUnscoped bool) error
+	Delete(ctx context.Context, whereMessage *models.Message) error
+	GetMessage(ctx context.Context, whereMessage *models.Message, isUnscoped bool) (*models.Message, error)
+	ReadMessageByRoomId(ctx context.Context, connectedUserID, roomId string, messageId *string) error
+	GetMessageHistoryByRoomID(ctx context.Context, roomId uuid.UUID, userId string) ([]*models.Message, error)
+	GetLastMessageTime(ctx context.Context, tx *gorm.DB, roomId uuid.UUID, senderId string) (*time.Time, error)
 	GetDB() *gorm.DB
 }
 
@@ -27,8 +33,8 @@ func NewMessageRepository(db *gorm.DB) IMessageRepository {
 }
 
 // region "Create" adds a new message to the database
-func (r *messageRepository) Create(tx *gorm.DB, message *models.Message) (*models.Message, error) {
-	db := r.DB
+func (r *messageRepository) Create(ctx context.Context, tx *gorm.DB, message *models.Message) (*models.Message, error) {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx // Use the provided transaction if available
 	}
@@ -41,9 +47,30 @@ func (r *messageRepository) Create(tx *gorm.DB, message *models.Message) (*model
 
 // endregion
 
+// region "CreateIfAbsent" adds a new message unless its sender already sent one with the same client message ID, reporting whether it was created
+func (r *messageRepository) CreateIfAbsent(ctx context.Context, tx *gorm.DB, message *models.Message) (bool, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	// A concurrent retry waits on the unique key and then becomes a no-op.
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "sender_id"}, {Name: "client_message_id"}},
+		DoNothing: true,
+	}).Create(message)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// endregion
+
 // region "Update" modifies the fields of a message in the database based on specified conditions
-func (r *messageRepository) UpdateExceptUpdatedAt(whereMessage *models.Message, updateMessage *models.Message, isUnscoped bool) error {
-	query := r.DB.Model(&models.Message{}).Where(whereMessage)
+func (r *messageRepository) UpdateExceptUpdatedAt(ctx context.Context, whereMessage *models.Message, updateMessage *models.Message, isUnscoped bool) error {
+	query := r.DB.WithContext(ctx).Model(&models.Message{}).Where(whereMessage)
 
 	if isUnscoped {
 		query = query.Unscoped() // Include soft-deleted messages in the update
@@ -55,15 +82,32 @@ func (r *messageRepository) UpdateExceptUpdatedAt(whereMessage *models.Message,
 // endregion
 
 // region "Delete" removes a message from the database
-func (r *messageRepository) Delete(whereMessage *models.Message) error {
-	return r.DB.Delete(whereMessage).Error
+func (r *messageRepository) Delete(ctx context.Context, whereMessage *models.Message) error {
+	return r.DB.WithContext(ctx).Delete(whereMessage).Error
+}
+
+// endregion
+
+// region "GetMessage" retrieves a single message based on specified conditions
+func (r *messageRepository) GetMessage(ctx context.Context, whereMessage *models.Message, isUnscoped bool) (*models.Message, error) {
+	query := r.DB.WithContext(ctx).Where(whereMessage)
+
+	if isUnscoped {
+		query = query.Unscoped() // Include soft-deleted messages in the lookup
+	}
+
+	var message *models.Message
+	if err := query.First(&message).Error; err != nil {
+		return nil, err
+	}
+	return message, nil
 }
 
 // endregion
 
 // region "ReadMessageByRoomId" marks a message as read for a specific user and room
-func (r *messageRepository) ReadMessageByRoomId(connectedUserID, roomId string, messageId *string) error {
-	query := r.DB.Model(&models.Message{}).Unscoped().Where("sender_id != ? AND room_id = ?", connectedUserID, roomId)
+func (r *messageRepository) ReadMessageByRoomId(ctx context.Context, connectedUserID, roomId string, messageId *string) error {
+	query := r.DB.WithContext(ctx).Model(&models.Message{}).Unscoped().Where("sender_id != ? AND room_id = ?", connectedUserID, roomId)
 
 	if messageId != nil {
 		query = query.Where("message_id = ?", *messageId) // Filter by message ID if provided
@@ -74,10 +118,10 @@ func (r *messageRepository) ReadMessageByRoomId(connectedUserID, roomId string,
 
 // endregion
 
-// region "GetMessageHistoryByRoomID" retrieves the message history for a specific room
-func (r *messageRepository) GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*models.Message, error) {
+// region "GetMessageHistoryByRoomID" retrieves the message history for a specific room, skipping messages the user hid or cleared for themselves
+func (r *messageRepository) GetMessageHistoryByRoomID(ctx context.Context, roomId uuid.UUID, userId string) ([]*models.Message, error) {
 	var messages []*models.Message
-	if err := r.DB.Unscoped().
+	if err := r.DB.WithContext(ctx).Unscoped().
 		Select(`
 			message_id, 
 			sender_id, 
@@ -90,6 +134,8 @@ func (r *messageRepository) GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*mode
 			CASE WHEN "deletedAt" IS NOT NULL THEN '' ELSE message END as message
 		`).
 		Where(&models.Message{RoomID: roomId}).
+		Where(`NOT EXISTS (SELECT 1 FROM "HIDDEN_MESSAGE" WHERE "HIDDEN_MESSAGE".message_id = "MESSAGE".message_id AND "HIDDEN_MESSAGE".user_id = ?)`, userId).
+		Where(`"MESSAGE"."createdAt" > COALESCE((SELECT "clearedAt" FROM "USER_ROOM" WHERE "USER_ROOM".room_id = "MESSAGE".room_id AND "USER_ROOM".user_id = ?), '-infinity')`, userId).
 		Order(`"createdAt" ASC`).
 		Find(&messages).Error; err != nil {
 		return nil, err
@@ -100,6 +146,27 @@ func (r *messageRepository) GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*mode
 
 // endregion
 
+// region "GetLastMessageTime" returns when the sender last posted in the room, or nil if they never did
+func (r *messageRepository) GetLastMessageTime(ctx context.Context, tx *gorm.DB, roomId uuid.UUID, senderId string) (*time.Time, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	var lastMessageTime *time.Time
+
+	// Deleted messages still count towards slow mode.
+	if err := db.Model(&models.Message{}).Unscoped().
+		Select(`MAX("createdAt")`).
+		Where(&models.Message{RoomID: roomId, SenderID: senderId}).
+		Scan(&lastMessageTime).Error; err != nil {
+		return nil, err
+	}
+	return lastMessageTime, nil
+}
+
+// endregion
+
 // region "GetDB" returns the underlying gorm.DB instance
 func (r *messageRepository) GetDB() *gorm.DB {
 	return r.DB // Return the database instance
@@ -1,18 +1,19 @@
 package repository
 
 import (
+	"context"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
 )
 
 type IRequestRepository interface {
-	Create(tx *gorm.DB, request *models.Request) error
-	Update(tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error
-	Delete(tx *gorm.DB, whereRequest *models.Request) error
-	GetRequests(receiverEmail string) ([]*models.Request, error)
-	GetSentRequests(senderEmail string) ([]*models.Request, error)
-	InsertAndReturnUser(request *models.Request) (*models.Request, error)
+	Create(ctx context.Context, tx *gorm.DB, request *models.Request) error
+	Update(ctx context.Context, tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error
+	Delete(ctx context.Context, tx *gorm.DB, whereRequest *models.Request) error
+	GetRequests(ctx context.Context, receiverEmail string) ([]*models.Request, error)
+	GetSentRequests(ctx context.Context, senderEmail string) ([]*models.Request, error)
+	InsertAndReturnUser(ctx context.Context, request *models.Request) (*models.Request, error)
 	GetDB() *gorm.DB
 }
 
@@ -27,8 +28,8 @@ func NewRequestRepository(db *gorm.DB) IRequestRepository {
 }
 
 // region "Create" adds a new request to the database
-func (r *requestRepository) Create(tx *gorm.DB, request *models.Request) error {
-	db := r.DB
+func (r *requestRepository) Create(ctx context.Context, tx *gorm.DB, request *models.Request) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx // Use the provided transaction if available
 	}
@@ -42,8 +43,8 @@ func (r *requestRepository) Create(tx *gorm.DB, request *models.Request) error {
 //endregion
 
 // region "Update" modifies the fields of a request in the database based on specified conditions
-func (r *requestRepository) Update(tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error {
-	db := r.DB
+func (r *requestRepository) Update(ctx context.Context, tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx
 	}
@@ -64,8 +65,8 @@ func (r *requestRepository) Update(tx *gorm.DB, whereRequest *models.Request, up
 //endregion
 
 // region "Delete" removes a request from the database
-func (r *requestRepository) Delete(tx *gorm.DB, whereRequest *models.Request) error {
-	db := r.DB
+func (r *requestRepository) Delete(ctx context.Context, tx *gorm.DB, whereRequest *models.Request) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx
 	}
@@ -87,10 +88,10 @@ func (r *requestRepository) Delete(tx *gorm.DB, whereRequest *models.Request) er
 //endregion
 
 // region "GetRequests" retrieves pending requests for a given receiver email.
-func (r *requestRepository) GetRequests(receiverEmail string) ([]*models.Request, error) {
+func (r *requestRepository) GetRequests(ctx context.Context, receiverEmail string) ([]*models.Request, error) {
 	var requests []*models.Request
 
-	if err := r.DB.
+	if err := r.DB.WithContext(ctx).
 		Where(&models.Request{ReceiverMail: receiverEmail, RequestStatus: types.Pending}).
 		Preload("User").
 		Find(&requests).Error; err != nil {
@@ -103,10 +104,10 @@ func (r *requestRepository) GetRequests(receiverEmail string) ([]*models.Request
 // endregion
 
 // region "GetSentRequests" retrieves sent requests for a given sender email
-func (r *requestRepository) GetSentRequests(senderEmail string) ([]*models.Request, error) {
+func (r *requestRepository) GetSentRequests(ctx context.Context, senderEmail string) ([]*models.Request, error) {
 	var requests []*models.Request
 
-	if err := r.DB.
+	if err := r.DB.WithContext(ctx).
 		Where(&models.Request{SenderMail: senderEmail, RequestStatus: types.Pending}).
 		Find(&requests).Error; err != nil {
 		return nil, err
@@ -118,10 +119,10 @@ func (r *requestRepository) GetSentRequests(senderEmail string) ([]*models.Reque
 // endregion
 
 // region "InsertAndReturnUser" creates a new request and returns the associated user.
-func (r *requestRepository) InsertAndReturnUser(request *models.Request) (*models.Request, error) {
+func (r *requestRepository) InsertAndReturnUser(ctx context.Context, request *models.Request) (*models.Request, error) {
 	var requestData *models.Request
 
-	if err := r.DB.Create(request).Preload("User").Find(&requestData).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Create(request).Preload("User").Find(&requestData).Error; err != nil {
 		return nil, err
 	}
 
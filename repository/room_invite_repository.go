@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IRoomInviteRepository interface {
+	Create(ctx context.Context, tx *gorm.DB, invite *models.RoomInvite) error
+	Update(ctx context.Context, tx *gorm.DB, whereInvite *models.RoomInvite, updateInvite *models.RoomInvite) error
+	GetInvite(ctx context.Context, tx *gorm.DB, whereInvite *models.RoomInvite, forUpdate bool) (*models.RoomInvite, error)
+	GetInvites(ctx context.Context, whereInvite *models.RoomInvite) ([]*models.RoomInvite, error)
+	CreateUse(ctx context.Context, tx *gorm.DB, inviteUse *models.RoomInviteUse) error
+	GetDB() *gorm.DB
+}
+
+type roomInviteRepository struct {
+	DB *gorm.DB
+}
+
+func NewRoomInviteRepository(db *gorm.DB) IRoomInviteRepository {
+	return &roomInviteRepository{
+		DB: db,
+	}
+}
+
+// region "Create" adds a new room invite to the database
+func (r *roomInviteRepository) Create(ctx context.Context, tx *gorm.DB, invite *models.RoomInvite) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	return db.Create(invite).Error
+}
+
+// endregion
+
+// region "Update" modifies the fields of a room invite in the database based on specified conditions
+func (r *roomInviteRepository) Update(ctx context.Context, tx *gorm.DB, whereInvite *models.RoomInvite, updateInvite *models.RoomInvite) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	result := db.Model(&models.RoomInvite{}).Where(whereInvite).Updates(updateInvite)
+
+	if result.Error != nil {
+		return result.Error // Return any error that occurs during the update
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound // Return an error if no records were affected
+	}
+
+	return nil
+}
+
+// endregion
+
+// region "GetInvite" retrieves a single room invite, optionally locking the row until the transaction ends
+func (r *roomInviteRepository) GetInvite(ctx context.Context, tx *gorm.DB, whereInvite *models.RoomInvite, forUpdate bool) (*models.RoomInvite, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	if forUpdate {
+		db = db.Clauses(clause.Locking{Strength: "UPDATE"}) // Serialize concurrent joins on the same invite
+	}
+
+	var invite *models.RoomInvite
+	if err := db.Where(whereInvite).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// endregion
+
+// region "GetInvites" retrieves invites with their usage history, newest first
+func (r *roomInviteRepository) GetInvites(ctx context.Context, whereInvite *models.RoomInvite) ([]*models.RoomInvite, error) {
+	var invites []*models.RoomInvite
+	if err := r.DB.WithContext(ctx).Preload("Uses").Where(whereInvite).Order(`"createdAt" DESC`).Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+// endregion
+
+// region "CreateUse" records that a user joined a room through an invite
+// A user who left and rejoins through the same invite keeps the record of their first use.
+func (r *roomInviteRepository) CreateUse(ctx context.Context, tx *gorm.DB, inviteUse *models.RoomInviteUse) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(inviteUse).Error
+}
+
+// endregion
+
+// region "GetDB" returns the underlying gorm.DB instance
+func (r *roomInviteRepository) GetDB() *gorm.DB {
+	return r.DB // Return the database instance
+}
+
+// endregion
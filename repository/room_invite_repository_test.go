@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newDryRunDB returns a database that builds statements without running them, and the SQL of every insert it built.
+func newDryRunDB(t *testing.T) (*gorm.DB, *[]string) {
+	t.Helper()
+
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var inserts []string
+	if err := db.Callback().Create().After("gorm:create").Register("test:capture", func(db *gorm.DB) {
+		inserts = append(inserts, db.Statement.SQL.String())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return db, &inserts
+}
+
+func TestCreateUseAllowsRejoiningThroughTheSameInvite(t *testing.T) {
+	db, inserts := newDryRunDB(t)
+	repo := NewRoomInviteRepository(db)
+	inviteId := uuid.New()
+
+	// The user joins, leaves, and joins again through the same invite.
+	for i := 0; i < 2; i++ {
+		if err := repo.CreateUse(context.Background(), nil, &models.RoomInviteUse{InviteID: inviteId, UserID: "user-1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(*inserts) != 2 {
+		t.Fatalf("built %d inserts, want 2", len(*inserts))
+	}
+	for _, insert := range *inserts {
+		if !strings.Contains(insert, `INSERT INTO "ROOM_INVITE_USE"`) || !strings.Contains(insert, "ON CONFLICT DO NOTHING") {
+			t.Errorf("use of an invite already used by the user would violate the primary key: %s", insert)
+		}
+	}
+}
@@ -1,17 +1,26 @@
 package repository
 
 import (
+	"context"
 	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"time"
 )
 
 type IRoomRepository interface {
-	Create(tx *gorm.DB, room *models.Room) (*models.Room, error)
-	Update(tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error
-	GetChatList(userId, userEmail string) ([]*ChatList, error)
+	Create(ctx context.Context, tx *gorm.DB, room *models.Room) (*models.Room, error)
+	CreatePrivate(ctx context.Context, tx *gorm.DB, room *models.Room) (bool, error)
+	Update(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error
+	UpdateFields(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, fields map[string]interface{}) error
+	GetRoom(ctx context.Context, whereRoom *models.Room) (*models.Room, error)
+	GetChatList(ctx context.Context, userId, userEmail string, includeArchived bool) ([]*ChatList, error)
+	SoftDeleteWithContents(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) error
+	GetDeletedRoomIDs(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error)
+	GetAttachmentURLs(ctx context.Context, roomId uuid.UUID) ([]string, error)
+	HardDeleteWithContents(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) error
 	GetDB() *gorm.DB
 }
 type roomRepository struct {
@@ -25,8 +34,8 @@ func NewRoomRepository(db *gorm.DB) IRoomRepository {
 }
 
 // region "Create" adds a new room to the database
-func (r *roomRepository) Create(tx *gorm.DB, room *models.Room) (*models.Room, error) {
-	db := r.DB
+func (r *roomRepository) Create(ctx context.Context, tx *gorm.DB, room *models.Room) (*models.Room, error) {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx // Use the provided transaction if available
 	}
@@ -38,9 +47,30 @@ func (r *roomRepository) Create(tx *gorm.DB, room *models.Room) (*models.Room, e
 
 //endregion
 
+// region "CreatePrivate" adds a new private room unless one already exists for its pair key, reporting whether it was created
+func (r *roomRepository) CreatePrivate(ctx context.Context, tx *gorm.DB, room *models.Room) (bool, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	// A concurrent insert for the same pair waits on the unique key and then becomes a no-op.
+	result := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "private_key"}},
+		DoNothing: true,
+	}).Create(room)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// endregion
+
 // region "Update" modifies the fields of a room in the database based on specified conditions
-func (r *roomRepository) Update(tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error {
-	db := r.DB
+func (r *roomRepository) Update(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx // Use the provided transaction if available
 	}
@@ -52,6 +82,28 @@ func (r *roomRepository) Update(tx *gorm.DB, whereRoom *models.Room, updateRoom
 
 // endregion
 
+// region "UpdateFields" sets the given columns of a room, including zero values
+func (r *roomRepository) UpdateFields(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, fields map[string]interface{}) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+	return db.Model(&models.Room{}).Where(whereRoom).Updates(fields).Error
+}
+
+// endregion
+
+// region "GetRoom" retrieves a single room based on specified conditions
+func (r *roomRepository) GetRoom(ctx context.Context, whereRoom *models.Room) (*models.Room, error) {
+	var room *models.Room
+	if err := r.DB.WithContext(ctx).Where(whereRoom).First(&room).Error; err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// endregion
+
 // region "GetChatList" DTO
 type ChatList struct {
 	RoomID           uuid.UUID          `json:"room_id"`                           // Unique identifier for the room
@@ -65,28 +117,42 @@ type ChatList struct {
 	LastMessageID    uuid.UUID          `json:"last_message_id" gorm:"type:uuid"`  // Identifier for the last message
 	MessageDeletedAt gorm.DeletedAt     `json:"message_deleted_at"`                // Timestamp when the last message was deleted
 	MessageType      types.MessageType  `json:"message_type" gorm:"type:message_type;not null"`
+	MutedUntil       *time.Time         `json:"mutedUntil" gorm:"column:mutedUntil"` // Notifications are muted for the user until this time
+	Archived         bool               `json:"archived"`                            // Whether the user archived the room
+	PinnedOrder      *int               `json:"pinned_order"`                        // Position among the user's pinned rooms, nil when not pinned
 }
 
 // endregion
 
 // region "GetChatList" retrieves the list of chat rooms for a user, including last message details
-func (r *roomRepository) GetChatList(userId, userEmail string) ([]*ChatList, error) {
+func (r *roomRepository) GetChatList(ctx context.Context, userId, userEmail string, includeArchived bool) ([]*ChatList, error) {
 	// GetChatList fetches all chat rooms associated with a user, including the last message details.
+	// Pinned rooms come first in their pinned order, followed by the rest by most recent activity.
 	// It returns a slice of ChatList and an error if the retrieval fails.
 
 	var chatLists []*ChatList
 
-	if err := r.DB.Model(&models.Room{}).Debug().
-		Select(`DISTINCT ON ("ROOM".room_id) "ROOM".room_id, "ROOM".last_message_id, "ROOM"."updatedAt", "USER".user_name, "USER".user_photo,"USER"."createdAt", "USER".user_email, "FRIEND".friend_status, "MESSAGE".message AS last_message,"MESSAGE".message_type,  "MESSAGE"."deletedAt" AS message_deleted_at`).
+	chatListQuery := r.DB.WithContext(ctx).Model(&models.Room{}).
+		Select(`DISTINCT ON ("ROOM".room_id) "ROOM".room_id, "ROOM".last_message_id, "ROOM"."updatedAt", "USER".user_name, "USER".user_photo,"USER"."createdAt", "USER".user_email, "FRIEND".friend_status, CASE WHEN "MESSAGE"."createdAt" <= "USER_ROOM"."clearedAt" THEN '' ELSE "MESSAGE".message END AS last_message,"MESSAGE".message_type,  "MESSAGE"."deletedAt" AS message_deleted_at, "USER_ROOM"."mutedUntil", "USER_ROOM".archived, "USER_ROOM".pinned_order`).
 		Joins(`INNER JOIN "USER_ROOM" ON "ROOM".room_id = "USER_ROOM".room_id`).
-		Joins(`LEFT JOIN "USER_ROOM" ur2 ON "ROOM".room_id = ur2.room_id AND ur2.user_id != ?`, userId).
+		Joins(`LEFT JOIN "USER_ROOM" ur2 ON "ROOM".room_id = ur2.room_id AND ur2.user_id != ? AND ur2."deletedAt" IS NULL`, userId).
 		Joins(`LEFT JOIN "USER" ON ur2.user_id = "USER".user_id`).
 		Joins(`LEFT JOIN "FRIEND" ON (("USER".user_email = "FRIEND".user_mail AND ? = "FRIEND".user_mail2) OR ("USER".user_email = "FRIEND".user_mail2 AND ? = "FRIEND".user_mail))`, userEmail, userEmail).
 		Joins(`LEFT JOIN "MESSAGE" ON "ROOM".last_message_id = "MESSAGE".message_id`).
 		Where(`"USER_ROOM".user_id = ?`, userId).
+		Where(`"USER_ROOM"."deletedAt" IS NULL`).
 		Where(`"MESSAGE".room_id IS NOT NULL`).
+		Where(`("USER_ROOM"."hiddenAt" IS NULL OR "MESSAGE"."createdAt" > "USER_ROOM"."hiddenAt")`). // Skip conversations the user deleted, until a new message arrives
 		Where(`"ROOM"."deletedAt" IS NULL`).
-		Order(`"ROOM".room_id, "ROOM"."updatedAt" DESC`).
+		Order(`"ROOM".room_id, "ROOM"."updatedAt" DESC`)
+
+	if !includeArchived {
+		chatListQuery = chatListQuery.Where(`"USER_ROOM".archived = false`) // Archived rooms are only listed on request
+	}
+
+	// DISTINCT ON dictates the inner ordering, so the display order is applied on the outer query.
+	if err := r.DB.WithContext(ctx).Table("(?) AS chat_list", chatListQuery).
+		Order(`pinned_order ASC NULLS LAST, "updatedAt" DESC`).
 		Scan(&chatLists).Error; err != nil {
 		return nil, err
 	}
@@ -96,6 +162,95 @@ func (r *roomRepository) GetChatList(userId, userEmail string) ([]*ChatList, err
 
 // endregion
 
+// region "SoftDeleteWithContents" soft-deletes a room together with its memberships, messages and invites
+func (r *roomRepository) SoftDeleteWithContents(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	// The room goes first so that joins waiting on its row see it deleted.
+	result := db.Where(&models.Room{RoomID: roomId}).Delete(&models.Room{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound // Return an error if the room does not exist or is already deleted
+	}
+
+	if err := db.Where(&models.Message{RoomID: roomId}).Delete(&models.Message{}).Error; err != nil {
+		return err
+	}
+
+	if err := db.Where(&models.UserRoom{RoomID: roomId}).Delete(&models.UserRoom{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where(&models.RoomInvite{RoomID: roomId}).Delete(&models.RoomInvite{}).Error
+}
+
+// endregion
+
+// region "GetDeletedRoomIDs" retrieves the IDs of rooms soft-deleted before the given time
+func (r *roomRepository) GetDeletedRoomIDs(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error) {
+	var roomIds []uuid.UUID
+	if err := r.DB.WithContext(ctx).Model(&models.Room{}).Unscoped().
+		Where(`"deletedAt" IS NOT NULL AND "deletedAt" < ?`, deletedBefore).
+		Pluck("room_id", &roomIds).Error; err != nil {
+		return nil, err
+	}
+	return roomIds, nil
+}
+
+// endregion
+
+// region "GetAttachmentURLs" retrieves the file URLs of all photo and file messages of a room, deleted or not
+func (r *roomRepository) GetAttachmentURLs(ctx context.Context, roomId uuid.UUID) ([]string, error) {
+	var urls []string
+	if err := r.DB.WithContext(ctx).Model(&models.Message{}).Unscoped().
+		Where(&models.Message{RoomID: roomId}).
+		Where("message_type IN ?", []types.MessageType{types.Photo, types.File}).
+		Pluck("message", &urls).Error; err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// endregion
+
+// region "HardDeleteWithContents" permanently removes a room and every row that belongs to it
+func (r *roomRepository) HardDeleteWithContents(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+	db = db.Unscoped()
+
+	// Delete dependent rows first so foreign keys are never violated.
+	statements := []struct {
+		query string
+		model interface{}
+	}{
+		{`message_id IN (SELECT message_id FROM "MESSAGE" WHERE room_id = ?)`, &models.HiddenMessage{}},
+		{`invite_id IN (SELECT invite_id FROM "ROOM_INVITE" WHERE room_id = ?)`, &models.RoomInviteUse{}},
+		{`room_id = ?`, &models.RoomInvite{}},
+		{`room_id = ?`, &models.Message{}},
+		{`room_id = ?`, &models.UserRoom{}},
+		{`room_id = ?`, &models.Room{}},
+	}
+
+	for _, statement := range statements {
+		if err := db.Where(statement.query, roomId).Delete(statement.model).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endregion
+
 // region "GetDB" returns the underlying gorm.DB instance
 func (r *roomRepository) GetDB() *gorm.DB {
 	return r.DB // Return the database instance
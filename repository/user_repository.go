@@ -1,16 +1,22 @@
 package repository
 
 import (
+	"context"
 	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type IUserRepository interface {
-	IsFieldUnique(whereUser *models.User) bool
-	IsFieldExists(whereUser *models.User) bool
-	Create(user *models.User) (*models.User, error)
-	GetUser(whereUser *models.User) (*models.User, error)
-	Update(whereUser *models.User, updates *models.User) error
+	IsFieldUnique(ctx context.Context, whereUser *models.User) bool
+	IsFieldExists(ctx context.Context, whereUser *models.User) bool
+	Create(ctx context.Context, user *models.User) (*models.User, error)
+	GetUser(ctx context.Context, whereUser *models.User) (*models.User, error)
+	Update(ctx context.Context, whereUser *models.User, updates *models.User) error
+	UpdateFields(ctx context.Context, whereUser *models.User, fields map[string]interface{}) error
+	ResetExpiredStatuses(ctx context.Context, expiredBefore time.Time) ([]string, error)
 }
 
 type userRepository struct {
@@ -24,26 +30,26 @@ func NewUserRepository(db *gorm.DB) IUserRepository {
 }
 
 // region "IsFieldUnique" checks if the specified fields in the User model are unique.
-func (r *userRepository) IsFieldUnique(whereUser *models.User) bool {
+func (r *userRepository) IsFieldUnique(ctx context.Context, whereUser *models.User) bool {
 	var count int64
-	r.DB.Model(&models.User{}).Where(whereUser).Count(&count)
+	r.DB.WithContext(ctx).Model(&models.User{}).Where(whereUser).Count(&count)
 	return count == 0
 }
 
 // endregion
 
 // region "IsFieldExists" checks if the specified fields in the User model exist in the database.
-func (r *userRepository) IsFieldExists(whereUser *models.User) bool {
+func (r *userRepository) IsFieldExists(ctx context.Context, whereUser *models.User) bool {
 	var count int64
-	r.DB.Model(&models.User{}).Debug().Where(whereUser).Count(&count)
+	r.DB.WithContext(ctx).Model(&models.User{}).Where(whereUser).Count(&count)
 	return count > 0
 }
 
 // endregion
 
 // region "Create" adds a new user to the database and returns the created user.
-func (r *userRepository) Create(user *models.User) (*models.User, error) {
-	if err := r.DB.Create(&user).Error; err != nil {
+func (r *userRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	if err := r.DB.WithContext(ctx).Create(&user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -52,9 +58,9 @@ func (r *userRepository) Create(user *models.User) (*models.User, error) {
 // endregion
 
 // region "GetUser" retrieves a user from the database based on the specified conditions.
-func (r *userRepository) GetUser(whereUser *models.User) (*models.User, error) {
+func (r *userRepository) GetUser(ctx context.Context, whereUser *models.User) (*models.User, error) {
 	var user *models.User
-	if err := r.DB.Where(whereUser).First(&user).Error; err != nil {
+	if err := r.DB.WithContext(ctx).Where(whereUser).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -63,8 +69,40 @@ func (r *userRepository) GetUser(whereUser *models.User) (*models.User, error) {
 // endregion
 
 // region "Update" modifies the fields of a user in the database based on specified conditions.
-func (r *userRepository) Update(whereUser *models.User, updates *models.User) error {
-	return r.DB.Model(&models.User{}).Where(whereUser).Updates(updates).Error
+func (r *userRepository) Update(ctx context.Context, whereUser *models.User, updates *models.User) error {
+	return r.DB.WithContext(ctx).Model(&models.User{}).Where(whereUser).Updates(updates).Error
+}
+
+// endregion
+
+// region "UpdateFields" sets the given columns of a user, including zero values.
+func (r *userRepository) UpdateFields(ctx context.Context, whereUser *models.User, fields map[string]interface{}) error {
+	return r.DB.WithContext(ctx).Model(&models.User{}).Where(whereUser).Updates(fields).Error
+}
+
+// endregion
+
+// region "ResetExpiredStatuses" resets the statuses that expired before the given time to available and returns the emails of their users.
+// Each status is reset by exactly one caller, so instances running this concurrently never report the same user twice.
+func (r *userRepository) ResetExpiredStatuses(ctx context.Context, expiredBefore time.Time) ([]string, error) {
+	var users []*models.User
+	if err := r.DB.WithContext(ctx).Model(&users).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_email"}}}).
+		Where(`"statusExpiresAt" <= ?`, expiredBefore).
+		Updates(map[string]interface{}{
+			"status":          types.Available, // Expired statuses fall back to available
+			"status_text":     nil,             // Clear the custom text
+			"status_emoji":    nil,             // Clear the custom emoji
+			"statusExpiresAt": nil,             // Nothing left to expire
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	emails := make([]string, 0, len(users))
+	for _, user := range users {
+		emails = append(emails, user.UserEmail)
+	}
+	return emails, nil
 }
 
 // endregion
@@ -1,14 +1,31 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type IUserRoomRepository interface {
-	Create(tx *gorm.DB, userRoom *models.UserRoom) error
-	GetPrivateRoom(userId1, userId2 string) (string, error)
+	Create(ctx context.Context, tx *gorm.DB, userRoom *models.UserRoom) error
+	Update(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom, updateUserRoom *models.UserRoom) error
+	UpdateFields(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom, fields map[string]interface{}) error
+	Delete(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) error
+	Restore(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) error
+	RoomExists(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) (bool, error)
+	GetUserRoom(ctx context.Context, whereUserRoom *models.UserRoom, isUnscoped bool) (*models.UserRoom, error)
+	LockUserRoom(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) (*models.UserRoom, error)
+	GetMembers(ctx context.Context, roomId uuid.UUID, limit, offset int) ([]*models.UserRoom, int64, error)
+	GetMutedMember(ctx context.Context, roomId uuid.UUID, userEmail string) (*models.UserRoom, error)
+	GetMaxPinnedOrder(ctx context.Context, userId string) (int, error)
+	GetRoomIDsByType(ctx context.Context, userId string, roomType types.RoomType) ([]uuid.UUID, error)
+	GetMemberEmails(ctx context.Context, roomId uuid.UUID) ([]string, error)
+	GetDB() *gorm.DB
 }
 
 type userRoomRepository struct {
@@ -22,8 +39,8 @@ func NewUserRoomRepository(db *gorm.DB) IUserRoomRepository {
 }
 
 // region "Create" adds a new user room to the database
-func (r *userRoomRepository) Create(tx *gorm.DB, userRoom *models.UserRoom) error {
-	db := r.DB
+func (r *userRoomRepository) Create(ctx context.Context, tx *gorm.DB, userRoom *models.UserRoom) error {
+	db := r.DB.WithContext(ctx)
 	if tx != nil {
 		db = tx // Use the provided transaction if available
 	}
@@ -36,29 +53,231 @@ func (r *userRoomRepository) Create(tx *gorm.DB, userRoom *models.UserRoom) erro
 
 //endregion
 
-// region GetPrivateRoom DTO represents the structure of a private room.
-type PrivateRoom struct {
-	RoomID       uuid.UUID `json:"room_id"`
-	FriendStatus string    `json:"friend_status"`
+// region "Update" modifies the fields of a user room in the database based on specified conditions
+func (r *userRoomRepository) Update(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom, updateUserRoom *models.UserRoom) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	result := db.Model(&models.UserRoom{}).Where(whereUserRoom).Updates(updateUserRoom)
+
+	if result.Error != nil {
+		return result.Error // Return any error that occurs during the update
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound // Return an error if the user is not a member of the room
+	}
+
+	return nil
 }
 
-// endregion
+//endregion
+
+// region "UpdateFields" sets the given columns of a user room, including zero and NULL values
+func (r *userRoomRepository) UpdateFields(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom, fields map[string]interface{}) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	result := db.Model(&models.UserRoom{}).Where(whereUserRoom).Updates(fields)
+
+	if result.Error != nil {
+		return result.Error // Return any error that occurs during the update
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound // Return an error if the user is not a member of the room
+	}
+
+	return nil
+}
+
+//endregion
 
-// region "GetPrivateRoom" fetches the room ID of a private room for the specified user IDs
-func (r *userRoomRepository) GetPrivateRoom(userId1, userId2 string) (string, error) {
-	var userRooms string
+// region "Delete" soft-deletes a room membership, keeping the row for bans and rejoins
+func (r *userRoomRepository) Delete(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	result := db.Where(whereUserRoom).Delete(&models.UserRoom{})
+
+	if result.Error != nil {
+		return result.Error // Return any error that occurs during the deletion
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound // Return an error if the user is not a member of the room
+	}
+
+	return nil
+}
+
+//endregion
+
+// region "Restore" reactivates a soft-deleted room membership as a regular member
+func (r *userRoomRepository) Restore(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) error {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	return db.Model(&models.UserRoom{}).Unscoped().
+		Where(whereUserRoom).
+		Updates(map[string]interface{}{
+			"deletedAt": nil,              // Reset the deletedAt field
+			"room_role": types.Member,     // Rejoining users start over as members
+			"createdAt": time.Now().UTC(), // Rejoining users only see what happens from now on
+		}).Error
+}
+
+//endregion
+
+// region "RoomExists" reports whether a room exists and is not deleted, locking it against deletion until the transaction ends
+func (r *userRoomRepository) RoomExists(ctx context.Context, tx *gorm.DB, roomId uuid.UUID) (bool, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
 
-	if err := r.DB.Model(&models.UserRoom{}).
-		Select(`"USER_ROOM".room_id`).
+	var room models.Room
+	err := db.Select("room_id").
+		Clauses(clause.Locking{Strength: "SHARE"}). // Hold the room until the membership is written
+		Where(&models.Room{RoomID: roomId}).
+		Take(&room).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+//endregion
+
+// region "GetUserRoom" retrieves a single room membership along with its room based on specified conditions
+func (r *userRoomRepository) GetUserRoom(ctx context.Context, whereUserRoom *models.UserRoom, isUnscoped bool) (*models.UserRoom, error) {
+	query := r.DB.WithContext(ctx).Preload("Room").Where(whereUserRoom)
+
+	if isUnscoped {
+		query = query.Unscoped() // Include memberships that were left, kicked or banned
+	}
+
+	var userRoom *models.UserRoom
+	if err := query.First(&userRoom).Error; err != nil {
+		return nil, err
+	}
+	return userRoom, nil
+}
+
+//endregion
+
+// region "LockUserRoom" retrieves an active room membership along with its room, locking the membership until the transaction ends
+func (r *userRoomRepository) LockUserRoom(ctx context.Context, tx *gorm.DB, whereUserRoom *models.UserRoom) (*models.UserRoom, error) {
+	db := r.DB.WithContext(ctx)
+	if tx != nil {
+		db = tx // Use the provided transaction if available
+	}
+
+	var userRoom *models.UserRoom
+	if err := db.Preload("Room").
+		Clauses(clause.Locking{Strength: "UPDATE"}). // Serialize the member's concurrent sends
+		Where(whereUserRoom).
+		First(&userRoom).Error; err != nil {
+		return nil, err
+	}
+	return userRoom, nil
+}
+
+//endregion
+
+// region "GetMembers" retrieves a page of a room's active members with their user profiles, plus the total member count
+func (r *userRoomRepository) GetMembers(ctx context.Context, roomId uuid.UUID, limit, offset int) ([]*models.UserRoom, int64, error) {
+	var total int64
+	if err := r.DB.WithContext(ctx).Model(&models.UserRoom{}).Where(&models.UserRoom{RoomID: roomId}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var members []*models.UserRoom
+	if err := r.DB.WithContext(ctx).Preload("User").
+		Where(&models.UserRoom{RoomID: roomId}).
+		Order(`"createdAt" ASC, user_id ASC`). // Stable order so pages do not overlap
+		Limit(limit).
+		Offset(offset).
+		Find(&members).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return members, total, nil
+}
+
+//endregion
+
+// region "GetMutedMember" retrieves the membership of the user with the given email, with their user profile, if they currently mute the room
+func (r *userRoomRepository) GetMutedMember(ctx context.Context, roomId uuid.UUID, userEmail string) (*models.UserRoom, error) {
+	var member *models.UserRoom
+	if err := r.DB.WithContext(ctx).Joins("User").
+		Where(`"USER_ROOM".room_id = ? AND "User".user_email = ?`, roomId, userEmail).
+		Where(`"USER_ROOM"."mutedUntil" > ?`, time.Now().UTC()).
+		First(&member).Error; err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+//endregion
+
+// region "GetMaxPinnedOrder" returns the highest pinned order among the user's rooms, or 0 when nothing is pinned
+func (r *userRoomRepository) GetMaxPinnedOrder(ctx context.Context, userId string) (int, error) {
+	var maxOrder int
+	if err := r.DB.WithContext(ctx).Model(&models.UserRoom{}).
+		Select("COALESCE(MAX(pinned_order), 0)").
+		Where(&models.UserRoom{UserID: userId}).
+		Scan(&maxOrder).Error; err != nil {
+		return 0, err
+	}
+	return maxOrder, nil
+}
+
+//endregion
+
+// region "GetRoomIDsByType" retrieves the IDs of the rooms of a given type the user is a member of
+func (r *userRoomRepository) GetRoomIDsByType(ctx context.Context, userId string, roomType types.RoomType) ([]uuid.UUID, error) {
+	var roomIds []uuid.UUID
+	if err := r.DB.WithContext(ctx).Model(&models.UserRoom{}).
 		Joins(`JOIN "ROOM" ON "USER_ROOM".room_id = "ROOM".room_id`).
-		Where(`"USER_ROOM".user_id IN (?,?) AND "ROOM".room_type = ?`, userId1, userId2, "private").
-		Group(`"USER_ROOM".room_id`).
-		Having("COUNT(DISTINCT user_id) = 2").
-		Find(&userRooms).Error; err != nil {
-		return "", err
+		Where(`"USER_ROOM".user_id = ? AND "ROOM".room_type = ? AND "ROOM"."deletedAt" IS NULL`, userId, roomType).
+		Pluck(`"USER_ROOM".room_id`, &roomIds).Error; err != nil {
+		return nil, err
 	}
+	return roomIds, nil
+}
+
+//endregion
 
-	return userRooms, nil
+// region "GetMemberEmails" retrieves the emails of all active members of a room
+func (r *userRoomRepository) GetMemberEmails(ctx context.Context, roomId uuid.UUID) ([]string, error) {
+	var emails []string
+	if err := r.DB.WithContext(ctx).Model(&models.UserRoom{}).
+		Joins(`JOIN "USER" ON "USER_ROOM".user_id = "USER".user_id`).
+		Where(`"USER_ROOM".room_id = ?`, roomId).
+		Pluck(`"USER".user_email`, &emails).Error; err != nil {
+		return nil, err
+	}
+	return emails, nil
 }
 
 //endregion
+
+// region "GetDB" returns the underlying gorm.DB instance
+func (r *userRoomRepository) GetDB() *gorm.DB {
+	return r.DB // Return the database instance
+}
+
+// endregion
@@ -5,9 +5,23 @@ import (
 	"github.com/kwa0x2/swiftchat-backend/controller"
 	"github.com/kwa0x2/swiftchat-backend/middlewares"
 	"github.com/kwa0x2/swiftchat-backend/socket/adapter"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/zishang520/socket.io/socket"
+	"net/http"
 )
 
+func HealthRoute(router *gin.Engine, healthController controller.IHealthController) {
+	healthRoutes := router.Group("/health")
+	{
+		healthRoutes.GET("live", healthController.Live)
+		healthRoutes.GET("ready", healthController.Ready)
+	}
+}
+
+func MetricsRoute(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", promhttp.Handler())
+}
+
 func AuthRoute(router *gin.Engine, authController controller.IAuthController) {
 	authRoutes := router.Group("/api/v1/auth")
 	{
@@ -24,6 +38,8 @@ func UserRoute(router *gin.Engine, userController controller.IUserController) {
 	{
 		userRoutes.PATCH("username", middlewares.SessionMiddleware(), userController.UpdateUsername)
 		userRoutes.POST("upload-profile-photo", middlewares.CombinedAuthMiddleware(), userController.UploadProfilePhoto)
+		userRoutes.GET("presence", middlewares.SessionMiddleware(), userController.GetPresence)
+		userRoutes.PATCH("privacy", middlewares.SessionMiddleware(), userController.UpdatePresenceVisibility)
 
 	}
 }
@@ -33,6 +49,17 @@ func MessageRoute(router *gin.Engine, messageController controller.IMessageContr
 	messageRoutes.Use(middlewares.SessionMiddleware())
 	{
 		messageRoutes.POST("history", messageController.GetMessageHistory)
+		messageRoutes.POST("", messageController.SendMessage)
+		messageRoutes.PATCH("", messageController.EditMessage)
+		messageRoutes.DELETE("", messageController.DeleteMessage)
+	}
+}
+
+func NotificationRoute(router *gin.Engine, notificationController controller.INotificationController) {
+	notificationRoutes := router.Group("/api/v1/notification")
+	notificationRoutes.Use(middlewares.SessionMiddleware())
+	{
+		notificationRoutes.GET("stream", notificationController.Stream)
 	}
 }
 
@@ -61,6 +88,38 @@ func RoomRoute(router *gin.Engine, roomController controller.IRoomController) {
 	{
 		roomRoutes.POST("check", roomController.GetOrCreatePrivateRoom)
 		roomRoutes.GET("chatlist", roomController.GetChatList)
+		roomRoutes.GET("members", roomController.GetMembers)
+		roomRoutes.DELETE("chatlist", roomController.RemoveFromChatList)
+		roomRoutes.POST("clear", roomController.ClearHistory)
+		roomRoutes.POST("leave", roomController.Leave)
+		roomRoutes.POST("kick", roomController.Kick)
+		roomRoutes.POST("ban", roomController.Ban)
+		roomRoutes.PATCH("mute", roomController.Mute)
+		roomRoutes.PATCH("archive", roomController.Archive)
+		roomRoutes.PATCH("pin", roomController.Pin)
+		roomRoutes.PATCH("slowmode", roomController.SetSlowMode)
+		roomRoutes.DELETE("", roomController.DeleteRoom)
+	}
+}
+
+func RoomInviteRoute(router *gin.Engine, roomInviteController controller.IRoomInviteController) {
+	inviteRoutes := router.Group("/api/v1/invite")
+	inviteRoutes.Use(middlewares.SessionMiddleware())
+	{
+		inviteRoutes.POST("", roomInviteController.Create)
+		inviteRoutes.GET("", roomInviteController.GetInvites)
+		inviteRoutes.DELETE("", roomInviteController.Revoke)
+		inviteRoutes.POST("join", roomInviteController.Join)
+	}
+}
+
+func ChannelRoute(router *gin.Engine, channelController controller.IChannelController) {
+	channelRoutes := router.Group("/api/v1/channel")
+	channelRoutes.Use(middlewares.SessionMiddleware())
+	{
+		channelRoutes.POST("", channelController.Create)
+		channelRoutes.POST("subscribe", channelController.Subscribe)
+		channelRoutes.POST("unsubscribe", channelController.Unsubscribe)
 	}
 }
 
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/gomodule/redigo/redis"
+	"gorm.io/gorm"
+)
+
+// HealthCheckTimeout bounds how long a single dependency check may take.
+const HealthCheckTimeout = 2 * time.Second
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+// HealthCheck reports whether a dependency is reachable.
+type HealthCheck func(ctx context.Context) error
+
+// region DependencyHealth is the result of checking a single dependency.
+type DependencyHealth struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// endregion
+
+// region HealthReport is the readiness of the app with a breakdown per dependency.
+type HealthReport struct {
+	Status       string                       `json:"status"`
+	Dependencies map[string]*DependencyHealth `json:"dependencies"`
+}
+
+// endregion
+
+type IHealthService interface {
+	CheckReadiness(ctx context.Context) *HealthReport
+}
+
+type healthService struct {
+	Checks  map[string]HealthCheck
+	Timeout time.Duration
+}
+
+func NewHealthService(checks map[string]HealthCheck, timeout time.Duration) IHealthService {
+	return &healthService{
+		Checks:  checks,
+		Timeout: timeout,
+	}
+}
+
+// region "CheckReadiness" checks every dependency concurrently, each within the service's timeout
+func (s *healthService) CheckReadiness(ctx context.Context) *HealthReport {
+	report := &HealthReport{
+		Status:       HealthStatusUp,
+		Dependencies: make(map[string]*DependencyHealth, len(s.Checks)),
+	}
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	for name, check := range s.Checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			result := s.runCheck(ctx, check)
+
+			mux.Lock()
+			defer mux.Unlock()
+			report.Dependencies[name] = result
+			if result.Status != HealthStatusUp {
+				report.Status = HealthStatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// endregion
+
+// region "runCheck" runs a single check, giving up once the timeout passes even if the check ignores its context
+func (s *healthService) runCheck(ctx context.Context, check HealthCheck) *DependencyHealth {
+	checkCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(checkCtx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-checkCtx.Done():
+		err = checkCtx.Err()
+	}
+
+	result := &DependencyHealth{
+		Status:    HealthStatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out"
+		}
+	}
+	return result
+}
+
+// endregion
+
+// region "PostgresHealthCheck" pings the PostgreSQL connection pool
+func PostgresHealthCheck(db *gorm.DB) HealthCheck {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return errors.New("database is not connected")
+		}
+
+		sqlDb, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDb.PingContext(ctx)
+	}
+}
+
+// endregion
+
+// region "RedisHealthCheck" sends a PING through a Redis pool
+func RedisHealthCheck(pool *redis.Pool) HealthCheck {
+	return func(ctx context.Context) error {
+		conn, err := pool.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		timeout := HealthCheckTimeout
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+
+		_, err = redis.DoWithTimeout(conn, timeout, "PING")
+		return err
+	}
+}
+
+// endregion
+
+// region "S3HealthCheck" checks that the bucket exists and is accessible with the client's credentials
+func S3HealthCheck(client *s3.Client, bucket string) HealthCheck {
+	return func(ctx context.Context) error {
+		if client == nil {
+			return errors.New("object storage client is not initialized")
+		}
+
+		_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+		return err
+	}
+}
+
+// endregion
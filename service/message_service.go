@@ -1,56 +1,136 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/repository"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"time"
 )
 
+// MaxClientMessageIDLength is the longest client message ID a sender can attach to a message.
+const MaxClientMessageIDLength = 64
+
+// DeleteForEveryoneWindow is how long after sending a message its sender may still delete it for everyone.
+const DeleteForEveryoneWindow = time.Hour
+
+var (
+	ErrNotMessageSender    = errors.New("only the sender can delete this message for everyone")
+	ErrNotMessageEditor    = errors.New("only the sender can edit this message")
+	ErrDeleteWindowExpired = errors.New("message can no longer be deleted for everyone")
+	ErrFriendBlocked       = errors.New("friend is blocked")
+	ErrInvalidClientID     = errors.New("client message id must be between 1 and 64 characters")
+)
+
+// SlowModeError is returned when a member posts again before the room's slow mode interval has passed.
+type SlowModeError struct {
+	RetryAfter time.Duration // How long the member has to wait before posting again.
+}
+
+func (e *SlowModeError) Error() string {
+	return fmt.Sprintf("slow mode is enabled, wait %d seconds before sending another message", int(e.RetryAfter.Seconds()))
+}
+
 type IMessageService interface {
-	Create(tx *gorm.DB, message *models.Message) (*models.Message, error)
-	InsertAndUpdateRoom(message *models.Message) (*models.Message, error)
-	GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*models.Message, error)
-	DeleteById(messageId uuid.UUID) error
-	UpdateMessageById(messageId uuid.UUID, message string) error
-	UpdateMessageStarredById(messageId uuid.UUID, messageStarred bool) error
-	ReadMessageByRoomId(connectedUserID, roomId string, messageId *string) error
+	Create(ctx context.Context, tx *gorm.DB, message *models.Message) (*models.Message, error)
+	InsertAndUpdateRoom(ctx context.Context, message *models.Message) (*models.Message, bool, error)
+	GetByClientMessageID(ctx context.Context, senderId, clientMessageId string) (*models.Message, error)
+	ValidateClientMessageID(clientMessageId *string) error
+	GetMessageHistoryByRoomID(ctx context.Context, roomId uuid.UUID, userId string) ([]*models.Message, error)
+	DeleteForEveryone(ctx context.Context, userId string, messageId uuid.UUID) (*models.Message, error)
+	DeleteForMe(ctx context.Context, userId string, messageId uuid.UUID) (*models.Message, error)
+	EditMessage(ctx context.Context, userId string, messageId uuid.UUID, message string) (*models.Message, error)
+	UpdateMessageStarredById(ctx context.Context, messageId uuid.UUID, messageStarred bool) error
+	ReadMessageByRoomId(ctx context.Context, connectedUserID, roomId string, messageId *string) error
 }
 
 type messageService struct {
-	MessageRepository repository.IMessageRepository
-	RoomService       IRoomService
+	MessageRepository       repository.IMessageRepository
+	HiddenMessageRepository repository.IHiddenMessageRepository
+	RoomService             IRoomService
+	UserRoomService         IUserRoomService
 }
 
-func NewMessageService(messageRepo repository.IMessageRepository, roomService IRoomService) IMessageService {
+func NewMessageService(messageRepo repository.IMessageRepository, hiddenMessageRepo repository.IHiddenMessageRepository, roomService IRoomService, userRoomService IUserRoomService) IMessageService {
 	return &messageService{
-		MessageRepository: messageRepo,
-		RoomService:       roomService,
+		MessageRepository:       messageRepo,
+		HiddenMessageRepository: hiddenMessageRepo,
+		RoomService:             roomService,
+		UserRoomService:         userRoomService,
 	}
 }
 
 // region "Create" adds a new message to the database
-func (s *messageService) Create(tx *gorm.DB, message *models.Message) (*models.Message, error) {
-	return s.MessageRepository.Create(tx, message)
+func (s *messageService) Create(ctx context.Context, tx *gorm.DB, message *models.Message) (*models.Message, error) {
+	return s.MessageRepository.Create(ctx, tx, message)
 }
 
 // endregion
 
-// region "InsertAndUpdateRoom" creates a new message and updates the corresponding room
-func (s *messageService) InsertAndUpdateRoom(message *models.Message) (*models.Message, error) {
+// region "InsertAndUpdateRoom" creates a new message and updates the corresponding room, enforcing the room's slow mode.
+// When the sender already sent a message with the same client message ID, that message is returned instead and the boolean is false.
+func (s *messageService) InsertAndUpdateRoom(ctx context.Context, message *models.Message) (*models.Message, bool, error) {
 	// Start a new database transaction.
-	tx := s.MessageRepository.GetDB().Begin()
+	tx := s.MessageRepository.GetDB().WithContext(ctx).Begin()
 	if tx.Error != nil {
 		// If starting the transaction failed, return the error.
-		return nil, tx.Error
+		return nil, false, tx.Error
 	}
 
-	// Create a new message and check for errors.
-	addedMessage, err := s.Create(tx, message)
+	// Lock the sender's membership so their concurrent sends are checked against slow mode one at a time.
+	userRoom, err := s.UserRoomService.LockUserRoom(ctx, tx, message.SenderID, message.RoomID)
 	if err != nil {
-		// Rollback the transaction in case of an error.
 		tx.Rollback()
-		return nil, err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrNotRoomMember
+		}
+		return nil, false, err
+	}
+
+	// A retry that waited for the lock finds the message its first attempt stored.
+	if message.ClientMessageID != nil {
+		original, getErr := s.GetByClientMessageID(ctx, message.SenderID, *message.ClientMessageID)
+		if getErr != nil || original != nil {
+			tx.Rollback()
+			return original, false, getErr
+		}
+	}
+
+	if slowModeErr := s.checkSlowMode(ctx, tx, message.SenderID, userRoom.Room, userRoom.RoomRole); slowModeErr != nil {
+		tx.Rollback()
+		return nil, false, slowModeErr
+	}
+
+	// Create a new message and check for errors.
+	if message.ClientMessageID == nil {
+		if _, err := s.Create(ctx, tx, message); err != nil {
+			// Rollback the transaction in case of an error.
+			tx.Rollback()
+			return nil, false, err
+		}
+	} else {
+		created, err := s.MessageRepository.CreateIfAbsent(ctx, tx, message)
+		if err != nil {
+			tx.Rollback()
+			return nil, false, err
+		}
+
+		// A retry of a message that was already stored: return the original instead of inserting again.
+		if !created {
+			tx.Rollback()
+			original, getErr := s.GetByClientMessageID(ctx, message.SenderID, *message.ClientMessageID)
+			if getErr != nil {
+				return nil, false, getErr
+			}
+			if original == nil {
+				return nil, false, gorm.ErrRecordNotFound
+			}
+			return original, false, nil
+		}
 	}
 
 	// Prepare the room data for updating.
@@ -64,60 +144,182 @@ func (s *messageService) InsertAndUpdateRoom(message *models.Message) (*models.M
 	}
 
 	// Update the room with the new last message details.
-	if updateErr := s.RoomService.Update(tx, whereRoom, updateRoom); updateErr != nil {
+	if updateErr := s.RoomService.Update(ctx, tx, whereRoom, updateRoom); updateErr != nil {
 		// Rollback the transaction if the update fails.
 		tx.Rollback()
-		return nil, updateErr
+		return nil, false, updateErr
 	}
 
 	// Commit the transaction if everything went smoothly.
 	if commitErr := tx.Commit().Error; commitErr != nil {
-		return nil, commitErr // Return the error if committing the transaction fails.
+		return nil, false, commitErr // Return the error if committing the transaction fails.
 	}
 
 	// Return the added message.
-	return addedMessage, nil
+	return message, true, nil
+}
+
+// endregion
+
+// region "GetByClientMessageID" retrieves the message a sender sent with the given client message ID, or nil if there is none
+func (s *messageService) GetByClientMessageID(ctx context.Context, senderId, clientMessageId string) (*models.Message, error) {
+	message, err := s.MessageRepository.GetMessage(ctx, &models.Message{SenderID: senderId, ClientMessageID: &clientMessageId}, true)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return message, nil
+}
+
+// endregion
+
+// region "ValidateClientMessageID" checks an optional client message ID
+func (s *messageService) ValidateClientMessageID(clientMessageId *string) error {
+	if clientMessageId != nil && (*clientMessageId == "" || len(*clientMessageId) > MaxClientMessageIDLength) {
+		return ErrInvalidClientID
+	}
+	return nil
+}
+
+// endregion
+
+// region "checkSlowMode" ensures a member waits the room's slow mode interval between messages; owners and admins are exempt
+func (s *messageService) checkSlowMode(ctx context.Context, tx *gorm.DB, senderId string, room *models.Room, roomRole types.RoomRole) error {
+	if room == nil || room.SlowModeSecs <= 0 || roomRole == types.Owner || roomRole == types.Admin {
+		return nil
+	}
+
+	lastMessageTime, err := s.MessageRepository.GetLastMessageTime(ctx, tx, room.RoomID, senderId)
+	if err != nil || lastMessageTime == nil {
+		return err
+	}
+
+	interval := time.Duration(room.SlowModeSecs) * time.Second
+	if elapsed := time.Since(*lastMessageTime); elapsed < interval {
+		// Round up so the client never retries a moment too early.
+		return &SlowModeError{RetryAfter: (interval - elapsed).Truncate(time.Second) + time.Second}
+	}
+
+	return nil
 }
 
 // endregion
 
-// region "GetMessageHistoryByRoomID" retrieves the message history for a specific room
-func (s *messageService) GetMessageHistoryByRoomID(roomId uuid.UUID) ([]*models.Message, error) {
-	return s.MessageRepository.GetMessageHistoryByRoomID(roomId)
+// region "GetMessageHistoryByRoomID" retrieves the message history of a specific room as seen by the given user
+func (s *messageService) GetMessageHistoryByRoomID(ctx context.Context, roomId uuid.UUID, userId string) ([]*models.Message, error) {
+	// Only active members may read the room; removed and banned members lose access to its history.
+	userRoom, err := s.UserRoomService.GetUserRoom(ctx, userId, roomId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRoomMember
+		}
+		return nil, err
+	}
+	if userRoom.BannedAt != nil {
+		return nil, ErrNotRoomMember
+	}
+
+	return s.MessageRepository.GetMessageHistoryByRoomID(ctx, roomId, userId)
 }
 
 // endregion
 
-// region "DeleteById" removes a message by its ID from the database
-func (s *messageService) DeleteById(messageId uuid.UUID) error {
+// region "DeleteForEveryone" soft-deletes a message for all participants if the user sent it within the allowed window, and returns the deleted message
+func (s *messageService) DeleteForEveryone(ctx context.Context, userId string, messageId uuid.UUID) (*models.Message, error) {
 	// Prepare the message data for deletion.
 	whereMessage := &models.Message{
 		MessageID: messageId, // Specify the message to delete using its ID.
 	}
 
-	return s.MessageRepository.Delete(whereMessage)
+	message, err := s.MessageRepository.GetMessage(ctx, whereMessage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the sender may delete a message for everyone.
+	if message.SenderID != userId {
+		return nil, ErrNotMessageSender
+	}
+
+	// Deleting for everyone is only allowed for a limited time after sending.
+	if time.Since(message.CreatedAt) > DeleteForEveryoneWindow {
+		return nil, ErrDeleteWindowExpired
+	}
+
+	if deleteErr := s.MessageRepository.Delete(ctx, whereMessage); deleteErr != nil {
+		return nil, deleteErr
+	}
+
+	return message, nil
+}
+
+// endregion
+
+// region "DeleteForMe" hides a message of one of the user's rooms from the user's history only, and returns the hidden message
+func (s *messageService) DeleteForMe(ctx context.Context, userId string, messageId uuid.UUID) (*models.Message, error) {
+	// Make sure the message exists before hiding it, including messages already deleted for everyone.
+	message, err := s.MessageRepository.GetMessage(ctx, &models.Message{MessageID: messageId}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only members of the message's room can see it, and so hide it.
+	if _, memberErr := s.UserRoomService.GetUserRoom(ctx, userId, message.RoomID); memberErr != nil {
+		if errors.Is(memberErr, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRoomMember
+		}
+		return nil, memberErr
+	}
+
+	hiddenMessage := &models.HiddenMessage{
+		UserID:    userId,    // The user hiding the message.
+		MessageID: messageId, // The message to hide.
+	}
+
+	if createErr := s.HiddenMessageRepository.Create(ctx, nil, hiddenMessage); createErr != nil {
+		return nil, createErr
+	}
+
+	return message, nil
 }
 
 // endregion
 
-// region "UpdateMessageById" updates the content of a message identified by its ID
-func (s *messageService) UpdateMessageById(messageId uuid.UUID, message string) error {
+// region "EditMessage" updates the content of a message, restricted to its sender, and returns the edited message
+func (s *messageService) EditMessage(ctx context.Context, userId string, messageId uuid.UUID, message string) (*models.Message, error) {
 	// Prepare the message data for updating.
 	whereMessage := &models.Message{
 		MessageID: messageId, // Specify the message to update using its ID.
 	}
 
+	existing, err := s.MessageRepository.GetMessage(ctx, whereMessage, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the sender may change what a message says.
+	if existing.SenderID != userId {
+		return nil, ErrNotMessageEditor
+	}
+
 	updateMessage := &models.Message{
 		Message: message, // Set the new message content.
 	}
 
-	return s.MessageRepository.UpdateExceptUpdatedAt(whereMessage, updateMessage, false)
+	if updateErr := s.MessageRepository.UpdateExceptUpdatedAt(ctx, whereMessage, updateMessage, false); updateErr != nil {
+		return nil, updateErr
+	}
+
+	existing.Message = message
+	return existing, nil
 }
 
 // endregion
 
 // region "UpdateMessageStarredById" updates the message type by its ID.
-func (s *messageService) UpdateMessageStarredById(messageId uuid.UUID, messageStarred bool) error {
+func (s *messageService) UpdateMessageStarredById(ctx context.Context, messageId uuid.UUID, messageStarred bool) error {
 	// Prepare the message data for starring.
 	whereMessage := &models.Message{
 		MessageID: messageId, // Specify the message to star using its ID.
@@ -127,14 +329,14 @@ func (s *messageService) UpdateMessageStarredById(messageId uuid.UUID, messageSt
 		MessageStarred: messageStarred, // Update the message type.
 	}
 
-	return s.MessageRepository.UpdateExceptUpdatedAt(whereMessage, updateMessage, false)
+	return s.MessageRepository.UpdateExceptUpdatedAt(ctx, whereMessage, updateMessage, false)
 }
 
 // endregion
 
 // region "ReadMessageByRoomId" marks a message as read for a specific user and room
-func (s *messageService) ReadMessageByRoomId(connectedUserID, roomId string, messageId *string) error {
-	return s.MessageRepository.ReadMessageByRoomId(connectedUserID, roomId, messageId)
+func (s *messageService) ReadMessageByRoomId(ctx context.Context, connectedUserID, roomId string, messageId *string) error {
+	return s.MessageRepository.ReadMessageByRoomId(ctx, connectedUserID, roomId, messageId)
 }
 
 // endregion
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/repository"
 	"github.com/kwa0x2/swiftchat-backend/types"
@@ -8,13 +9,13 @@ import (
 )
 
 type IRequestService interface {
-	Create(tx *gorm.DB, request *models.Request) error
-	Update(tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error
-	DeleteByEmail(tx *gorm.DB, receiverEmail, senderEmail string) error
-	GetRequests(receiverEmail string) ([]*models.Request, error)
-	GetSentRequests(senderEmail string) ([]*models.Request, error)
-	UpdateFriendshipRequest(receiverEmail, senderEmail string, requestStatus types.RequestStatus) (map[string]interface{}, error)
-	InsertAndReturnUser(request *models.Request) (map[string]interface{}, error)
+	Create(ctx context.Context, tx *gorm.DB, request *models.Request) error
+	Update(ctx context.Context, tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error
+	DeleteByEmail(ctx context.Context, tx *gorm.DB, receiverEmail, senderEmail string) error
+	GetRequests(ctx context.Context, receiverEmail string) ([]*models.Request, error)
+	GetSentRequests(ctx context.Context, senderEmail string) ([]*models.Request, error)
+	UpdateFriendshipRequest(ctx context.Context, receiverEmail, senderEmail string, requestStatus types.RequestStatus) (map[string]interface{}, error)
+	InsertAndReturnUser(ctx context.Context, request *models.Request) (map[string]interface{}, error)
 }
 
 type requestService struct {
@@ -32,48 +33,48 @@ func NewRequestService(requestRepository repository.IRequestRepository, friendSe
 }
 
 // region "Create" adds a new request to the database
-func (s *requestService) Create(tx *gorm.DB, request *models.Request) error {
-	return s.RequestRepository.Create(tx, request)
+func (s *requestService) Create(ctx context.Context, tx *gorm.DB, request *models.Request) error {
+	return s.RequestRepository.Create(ctx, tx, request)
 }
 
 //endregion
 
 // region "Update" modifies the fields of a request in the database based on specified conditions
-func (s *requestService) Update(tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error {
-	return s.RequestRepository.Update(tx, whereRequest, updateRequest)
+func (s *requestService) Update(ctx context.Context, tx *gorm.DB, whereRequest *models.Request, updateRequest *models.Request) error {
+	return s.RequestRepository.Update(ctx, tx, whereRequest, updateRequest)
 }
 
 //endregion
 
 // region "DeleteByEmail" removes a request based on the provided email information.
-func (s *requestService) DeleteByEmail(tx *gorm.DB, receiverEmail, senderEmail string) error {
+func (s *requestService) DeleteByEmail(ctx context.Context, tx *gorm.DB, receiverEmail, senderEmail string) error {
 	whereRequest := &models.Request{
 		ReceiverMail: receiverEmail,
 		SenderMail:   senderEmail,
 	}
 
-	return s.RequestRepository.Delete(tx, whereRequest)
+	return s.RequestRepository.Delete(ctx, tx, whereRequest)
 }
 
 //endregion
 
 // region "GetRequests" retrieves requests for a given receiver email
-func (s *requestService) GetRequests(receiverEmail string) ([]*models.Request, error) {
-	return s.RequestRepository.GetRequests(receiverEmail)
+func (s *requestService) GetRequests(ctx context.Context, receiverEmail string) ([]*models.Request, error) {
+	return s.RequestRepository.GetRequests(ctx, receiverEmail)
 }
 
 //endregion
 
 // region "GetSentRequests" retrieves sent requests for a given sender email
-func (s *requestService) GetSentRequests(senderEmail string) ([]*models.Request, error) {
-	return s.RequestRepository.GetSentRequests(senderEmail)
+func (s *requestService) GetSentRequests(ctx context.Context, senderEmail string) ([]*models.Request, error) {
+	return s.RequestRepository.GetSentRequests(ctx, senderEmail)
 }
 
 //endregion
 
 // region "UpdateFriendshipRequest" updates the status of a friendship request and manages friendship creation
-func (s *requestService) UpdateFriendshipRequest(receiverEmail, senderEmail string, requestStatus types.RequestStatus) (map[string]interface{}, error) {
-	tx := s.RequestRepository.GetDB().Begin() // Start a new database transaction
+func (s *requestService) UpdateFriendshipRequest(ctx context.Context, receiverEmail, senderEmail string, requestStatus types.RequestStatus) (map[string]interface{}, error) {
+	tx := s.RequestRepository.GetDB().WithContext(ctx).Begin() // Start a new database transaction
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
@@ -90,19 +91,19 @@ func (s *requestService) UpdateFriendshipRequest(receiverEmail, senderEmail stri
 	}
 
 	// Update the friendship request status in the database
-	if err := s.Update(tx, whereRequest, updateRequest); err != nil {
+	if err := s.Update(ctx, tx, whereRequest, updateRequest); err != nil {
 		tx.Rollback() // Rollback the transaction on error
 		return nil, err
 	}
 
 	// Delete the request from the database after handling the response
-	if err := s.DeleteByEmail(tx, receiverEmail, senderEmail); err != nil {
+	if err := s.DeleteByEmail(ctx, tx, receiverEmail, senderEmail); err != nil {
 		tx.Rollback() // Rollback the transaction on error
 		return nil, err
 	}
 
 	// Retrieve user data of the receiver for the response
-	userData, err := s.UserService.GetByEmail(receiverEmail)
+	userData, err := s.UserService.GetByEmail(ctx, receiverEmail)
 	if err != nil {
 		tx.Rollback() // Rollback the transaction on error
 		return nil, err
@@ -117,7 +118,7 @@ func (s *requestService) UpdateFriendshipRequest(receiverEmail, senderEmail stri
 			FriendStatus: "friend",
 		}
 
-		if createErr := s.FriendService.Create(tx, friend); createErr != nil {
+		if createErr := s.FriendService.Create(ctx, tx, friend); createErr != nil {
 			tx.Rollback() // Rollback the transaction on error
 			return nil, createErr
 		}
@@ -153,20 +154,20 @@ func (s *requestService) UpdateFriendshipRequest(receiverEmail, senderEmail stri
 //endregion
 
 // region "InsertAndReturnUser" adds a new request and returns associated user data.
-func (s *requestService) InsertAndReturnUser(request *models.Request) (map[string]interface{}, error) {
-	tx := s.RequestRepository.GetDB().Begin() // Start a new database transaction
+func (s *requestService) InsertAndReturnUser(ctx context.Context, request *models.Request) (map[string]interface{}, error) {
+	tx := s.RequestRepository.GetDB().WithContext(ctx).Begin() // Start a new database transaction
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 
 	// Create the new request in the database
-	if err := s.Create(tx, request); err != nil {
+	if err := s.Create(ctx, tx, request); err != nil {
 		tx.Rollback() // Rollback the transaction on error
 		return nil, err
 	}
 
 	// Retrieve user data of the sender for the response
-	userData, err := s.UserService.GetByEmail(request.SenderMail)
+	userData, err := s.UserService.GetByEmail(ctx, request.SenderMail)
 	if err != nil {
 		tx.Rollback() // Rollback the transaction on error
 		return nil, err
@@ -1,13 +1,17 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
+	"github.com/kwa0x2/swiftchat-backend/internal/tracing"
 	"github.com/kwa0x2/swiftchat-backend/utils"
 	"github.com/resend/resend-go/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type IResendService interface {
-	SendEmail(to, subject, template string) (string, error)
+	SendEmail(ctx context.Context, to, subject, template string) (string, error)
 }
 
 type resendService struct {
@@ -28,7 +32,7 @@ type EmailData struct {
 // endregion
 
 // region "SendEmail" sends an email using the Resend client.
-func (s *resendService) SendEmail(to, subject, template string) (string, error) {
+func (s *resendService) SendEmail(ctx context.Context, to, subject, template string) (string, error) {
 	// Load the HTML template and replace placeholders with the recipient's information.
 	htmlContent, err := utils.LoadTemplate(template, to)
 	if err != nil {
@@ -43,7 +47,10 @@ func (s *resendService) SendEmail(to, subject, template string) (string, error)
 		Subject: subject,                                   // Subject of the email.
 	}
 
-	sent, sentErr := s.ResendClient.Emails.Send(params)
+	ctx, span := tracing.Start(ctx, "resend.SendEmail", attribute.String("email.subject", subject))
+	sent, sentErr := s.ResendClient.Emails.SendWithContext(ctx, params)
+	tracing.End(span, sentErr)
+	metrics.EmailSends.WithLabelValues(metrics.Outcome(sentErr)).Inc()
 	if sentErr != nil {
 		return "", fmt.Errorf("error sending email: %w", sentErr)
 	}
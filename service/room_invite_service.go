@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/repository"
+	"github.com/kwa0x2/swiftchat-backend/types"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"gorm.io/gorm"
+	"time"
+)
+
+// inviteTokenBytes is the amount of random data behind each invite token.
+const inviteTokenBytes = 32
+
+var (
+	ErrInviteNotFound  = errors.New("invite not found")
+	ErrInviteRevoked   = errors.New("invite has been revoked")
+	ErrInviteExpired   = errors.New("invite has expired")
+	ErrInviteExhausted = errors.New("invite has reached its usage limit")
+)
+
+type IRoomInviteService interface {
+	Create(ctx context.Context, userId string, roomId uuid.UUID, expiresAt *time.Time, maxUses *int) (*models.RoomInvite, error)
+	Revoke(ctx context.Context, userId string, inviteId uuid.UUID) error
+	GetInvitesByRoomID(ctx context.Context, userId string, roomId uuid.UUID) ([]*models.RoomInvite, error)
+	Join(ctx context.Context, userId, token string) (*models.RoomInvite, error)
+}
+
+type roomInviteService struct {
+	RoomInviteRepository repository.IRoomInviteRepository
+	RoomService          IRoomService
+	UserRoomService      IUserRoomService
+}
+
+func NewRoomInviteService(roomInviteRepository repository.IRoomInviteRepository, roomService IRoomService, userRoomService IUserRoomService) IRoomInviteService {
+	return &roomInviteService{
+		RoomInviteRepository: roomInviteRepository,
+		RoomService:          roomService,
+		UserRoomService:      userRoomService,
+	}
+}
+
+// region "Create" generates a new invite token for a group room or channel, restricted to its owners and admins
+func (s *roomInviteService) Create(ctx context.Context, userId string, roomId uuid.UUID, expiresAt *time.Time, maxUses *int) (*models.RoomInvite, error) {
+	if err := s.checkGroupAdmin(ctx, userId, roomId); err != nil {
+		return nil, err
+	}
+
+	// Generate an unguessable token for the invite link.
+	token, err := utils.GenerateRandomToken(inviteTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &models.RoomInvite{
+		RoomID:        roomId,    // The room the invite grants access to.
+		Token:         token,     // The shareable token.
+		CreatedUserID: userId,    // Who created the invite, for auditing.
+		ExpiresAt:     expiresAt, // Optional expiry time.
+		MaxUses:       maxUses,   // Optional usage limit.
+	}
+
+	if createErr := s.RoomInviteRepository.Create(ctx, nil, invite); createErr != nil {
+		return nil, createErr
+	}
+
+	return invite, nil
+}
+
+// endregion
+
+// region "Revoke" disables an invite so it can no longer be used, keeping it for auditing
+func (s *roomInviteService) Revoke(ctx context.Context, userId string, inviteId uuid.UUID) error {
+	invite, err := s.RoomInviteRepository.GetInvite(ctx, nil, &models.RoomInvite{InviteID: inviteId}, false)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInviteNotFound
+		}
+		return err
+	}
+
+	if adminErr := s.checkGroupAdmin(ctx, userId, invite.RoomID); adminErr != nil {
+		return adminErr
+	}
+
+	if invite.RevokedAt != nil {
+		return ErrInviteRevoked
+	}
+
+	now := time.Now().UTC()
+	updateInvite := &models.RoomInvite{
+		RevokedUserID: &userId, // Who revoked the invite, for auditing.
+		RevokedAt:     &now,    // When the invite was revoked.
+	}
+
+	return s.RoomInviteRepository.Update(ctx, nil, &models.RoomInvite{InviteID: inviteId}, updateInvite)
+}
+
+// endregion
+
+// region "GetInvitesByRoomID" lists the invites of a room together with who used them
+func (s *roomInviteService) GetInvitesByRoomID(ctx context.Context, userId string, roomId uuid.UUID) ([]*models.RoomInvite, error) {
+	if err := s.checkGroupAdmin(ctx, userId, roomId); err != nil {
+		return nil, err
+	}
+
+	return s.RoomInviteRepository.GetInvites(ctx, &models.RoomInvite{RoomID: roomId})
+}
+
+// endregion
+
+// region "Join" adds the user to the room behind the given invite token within a transaction
+func (s *roomInviteService) Join(ctx context.Context, userId, token string) (*models.RoomInvite, error) {
+	tx := s.RoomInviteRepository.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// Lock the invite so concurrent joins cannot exceed the usage limit.
+	invite, err := s.RoomInviteRepository.GetInvite(ctx, tx, &models.RoomInvite{Token: token}, true)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteNotFound
+		}
+		return nil, err
+	}
+
+	// Validate the invite before using it.
+	if validateErr := validateInvite(invite); validateErr != nil {
+		tx.Rollback()
+		return nil, validateErr
+	}
+
+	// Add the user to the room, refusing current members and banned users.
+	if memberErr := s.UserRoomService.AddMember(ctx, tx, userId, invite.RoomID); memberErr != nil {
+		tx.Rollback()
+		return nil, memberErr
+	}
+
+	// Count the use of the invite.
+	invite.UseCount++
+	if updateErr := s.RoomInviteRepository.Update(ctx, tx, &models.RoomInvite{InviteID: invite.InviteID}, &models.RoomInvite{UseCount: invite.UseCount}); updateErr != nil {
+		tx.Rollback()
+		return nil, updateErr
+	}
+
+	// Record who used the invite, for auditing.
+	inviteUse := &models.RoomInviteUse{
+		InviteID: invite.InviteID,
+		UserID:   userId,
+	}
+
+	if useErr := s.RoomInviteRepository.CreateUse(ctx, tx, inviteUse); useErr != nil {
+		tx.Rollback()
+		return nil, useErr
+	}
+
+	if commitErr := tx.Commit().Error; commitErr != nil {
+		return nil, commitErr
+	}
+
+	return invite, nil
+}
+
+// endregion
+
+// region "checkGroupAdmin" ensures the room is a group or channel and the user is one of its owners or admins
+func (s *roomInviteService) checkGroupAdmin(ctx context.Context, userId string, roomId uuid.UUID) error {
+	room, err := s.RoomService.GetById(ctx, roomId)
+	if err != nil {
+		return err
+	}
+
+	if room.RoomType != types.Group && room.RoomType != types.Channel {
+		return ErrNotGroupRoom
+	}
+
+	userRoom, userRoomErr := s.UserRoomService.GetUserRoom(ctx, userId, roomId)
+	if userRoomErr != nil {
+		if errors.Is(userRoomErr, gorm.ErrRecordNotFound) {
+			return ErrNotRoomAdmin
+		}
+		return userRoomErr
+	}
+
+	if userRoom.RoomRole != types.Owner && userRoom.RoomRole != types.Admin {
+		return ErrNotRoomAdmin
+	}
+
+	return nil
+}
+
+// endregion
+
+// region "validateInvite" checks that an invite is neither revoked, expired nor used up
+func validateInvite(invite *models.RoomInvite) error {
+	if invite.RevokedAt != nil {
+		return ErrInviteRevoked
+	}
+
+	if invite.ExpiresAt != nil && time.Now().After(*invite.ExpiresAt) {
+		return ErrInviteExpired
+	}
+
+	if invite.MaxUses != nil && invite.UseCount >= *invite.MaxUses {
+		return ErrInviteExhausted
+	}
+
+	return nil
+}
+
+// endregion
@@ -1,49 +1,88 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/repository"
 	"github.com/kwa0x2/swiftchat-backend/types"
+	"github.com/kwa0x2/swiftchat-backend/utils"
 	"gorm.io/gorm"
+	"time"
+)
+
+// MaxSlowModeSeconds is the longest slow mode interval a room can be configured with.
+const MaxSlowModeSeconds = 3600
+
+var (
+	ErrNotChannelRoom       = errors.New("room is not a channel")
+	ErrInvalidSlowMode      = errors.New("slow mode interval is out of range")
+	ErrChannelPostForbidden = errors.New("only channel owners and admins can post in a channel")
+	ErrNotRoomOwner         = errors.New("only the room owner can perform this action")
 )
 
 type IRoomService interface {
-	Create(tx *gorm.DB, room *models.Room) (*models.Room, error)
-	Update(tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error
-	CreateAndAddUsers(createdUserId string, userId2 string, roomType types.RoomType) (string, error)
-	GetChatList(userId, userEmail string) ([]*repository.ChatList, error)
+	Create(ctx context.Context, tx *gorm.DB, room *models.Room) (*models.Room, error)
+	Update(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error
+	GetById(ctx context.Context, roomId uuid.UUID) (*models.Room, error)
+	GetOrCreatePrivateRoom(ctx context.Context, createdUserId string, userId2 string) (string, error)
+	GetChatList(ctx context.Context, userId, userEmail string, includeArchived bool) ([]*repository.ChatList, error)
+	CreateChannel(ctx context.Context, createdUserId string) (string, error)
+	Subscribe(ctx context.Context, userId string, roomId uuid.UUID) error
+	Unsubscribe(ctx context.Context, userId string, roomId uuid.UUID) error
+	SetSlowMode(ctx context.Context, userId string, roomId uuid.UUID, seconds int) error
+	DeleteRoom(ctx context.Context, userId string, roomId uuid.UUID) ([]string, error)
+	PurgeDeletedRooms(ctx context.Context, gracePeriod time.Duration) error
 }
 
 type roomService struct {
 	RoomRepository  repository.IRoomRepository
 	UserRoomService IUserRoomService
+	S3Service       IS3Service
 }
 
-func NewRoomService(roomRepository repository.IRoomRepository, UserRoomService IUserRoomService) IRoomService {
+func NewRoomService(roomRepository repository.IRoomRepository, UserRoomService IUserRoomService, s3Service IS3Service) IRoomService {
 	return &roomService{
 		RoomRepository:  roomRepository,
 		UserRoomService: UserRoomService,
+		S3Service:       s3Service,
 	}
 }
 
 // region "Create" adds a new room to the database
-func (s *roomService) Create(tx *gorm.DB, room *models.Room) (*models.Room, error) {
-	return s.RoomRepository.Create(tx, room)
+func (s *roomService) Create(ctx context.Context, tx *gorm.DB, room *models.Room) (*models.Room, error) {
+	return s.RoomRepository.Create(ctx, tx, room)
 }
 
 //endregion
 
 // region "Update" modifies the fields of a friend in the database based on specified conditions
-func (s *roomService) Update(tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error {
-	return s.RoomRepository.Update(tx, whereRoom, updateRoom)
+func (s *roomService) Update(ctx context.Context, tx *gorm.DB, whereRoom *models.Room, updateRoom *models.Room) error {
+	return s.RoomRepository.Update(ctx, tx, whereRoom, updateRoom)
 }
 
 // endregion
 
-// region "CreateAndAddUsers" creates a new room and adds users to the user room within a transaction.
-func (s *roomService) CreateAndAddUsers(createdUserId string, userId2 string, roomType types.RoomType) (string, error) {
+// region "GetById" retrieves a room by its ID
+func (s *roomService) GetById(ctx context.Context, roomId uuid.UUID) (*models.Room, error) {
+	return s.RoomRepository.GetRoom(ctx, &models.Room{RoomID: roomId})
+}
+
+// endregion
+
+// region "GetOrCreatePrivateRoom" returns the private room between two users, creating it with both memberships if it does not exist yet.
+func (s *roomService) GetOrCreatePrivateRoom(ctx context.Context, createdUserId string, userId2 string) (string, error) {
+	privateKey := utils.PrivateRoomKey(createdUserId, userId2)
+
+	// Reuse the existing room when there is one.
+	if roomId, err := s.getPrivateRoomId(ctx, privateKey); err != nil || roomId != "" {
+		return roomId, err
+	}
+
 	// Begin a new database transaction.
-	tx := s.RoomRepository.GetDB().Begin()
+	tx := s.RoomRepository.GetDB().WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return "", tx.Error
 	}
@@ -51,24 +90,37 @@ func (s *roomService) CreateAndAddUsers(createdUserId string, userId2 string, ro
 	// Create a new room object.
 	roomObj := &models.Room{
 		CreatedUserID: createdUserId, // Set the creator of the room.
-		RoomType:      roomType,      // Set the type of the room.
+		RoomType:      types.Private, // Set the type of the room.
+		PrivateKey:    &privateKey,   // Set the key that keeps the pair unique.
 	}
 
 	// Create the room in the database.
-	room, err := s.Create(tx, roomObj)
+	created, err := s.RoomRepository.CreatePrivate(ctx, tx, roomObj)
 	if err != nil {
 		tx.Rollback() // Roll back the transaction on error.
 		return "", err
 	}
 
-	// Add users to the newly created room.
+	// Another request created the room first; return that one instead.
+	if !created {
+		tx.Rollback()
+		return s.getPrivateRoomId(ctx, privateKey)
+	}
+
+	// Add users to the newly created room, the creator becoming its owner.
 	for _, userId := range []string{createdUserId, userId2} {
+		roomRole := types.Member
+		if userId == createdUserId {
+			roomRole = types.Owner
+		}
+
 		userRoom := &models.UserRoom{
-			UserID: userId,      // Assign the user ID.
-			RoomID: room.RoomID, // Assign the room ID.
+			UserID:   userId,         // Assign the user ID.
+			RoomID:   roomObj.RoomID, // Assign the room ID.
+			RoomRole: roomRole,       // Assign the user's role in the room.
 		}
 		// Create the user-room association.
-		if createErr := s.UserRoomService.Create(tx, userRoom); createErr != nil {
+		if createErr := s.UserRoomService.Create(ctx, tx, userRoom); createErr != nil {
 			tx.Rollback() // Roll back the transaction on error.
 			return "", createErr
 		}
@@ -79,14 +131,231 @@ func (s *roomService) CreateAndAddUsers(createdUserId string, userId2 string, ro
 		return "", commitErr // Return an error if committing fails.
 	}
 
-	return room.RoomID.String(), nil
+	return roomObj.RoomID.String(), nil
 }
 
 //endregion
 
+// region "getPrivateRoomId" returns the ID of the private room with the given pair key, or an empty string if there is none.
+func (s *roomService) getPrivateRoomId(ctx context.Context, privateKey string) (string, error) {
+	room, err := s.RoomRepository.GetRoom(ctx, &models.Room{PrivateKey: &privateKey})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	return room.RoomID.String(), nil
+}
+
+// endregion
+
 // region "GetChatList" retrieves the list of chat rooms for a user, including last message details
-func (s *roomService) GetChatList(userId, userEmail string) ([]*repository.ChatList, error) {
-	return s.RoomRepository.GetChatList(userId, userEmail)
+func (s *roomService) GetChatList(ctx context.Context, userId, userEmail string, includeArchived bool) ([]*repository.ChatList, error) {
+	return s.RoomRepository.GetChatList(ctx, userId, userEmail, includeArchived)
+}
+
+// endregion
+
+// region "CreateChannel" creates a new broadcast channel owned by the given user within a transaction.
+func (s *roomService) CreateChannel(ctx context.Context, createdUserId string) (string, error) {
+	// Begin a new database transaction.
+	tx := s.RoomRepository.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return "", tx.Error
+	}
+
+	// Create the channel in the database.
+	room, err := s.Create(ctx, tx, &models.Room{CreatedUserID: createdUserId, RoomType: types.Channel})
+	if err != nil {
+		tx.Rollback() // Roll back the transaction on error.
+		return "", err
+	}
+
+	// The creator owns the channel and is the only one allowed to post at first.
+	userRoom := &models.UserRoom{
+		UserID:   createdUserId, // Assign the user ID.
+		RoomID:   room.RoomID,   // Assign the room ID.
+		RoomRole: types.Owner,   // Assign the owner role.
+	}
+	if createErr := s.UserRoomService.Create(ctx, tx, userRoom); createErr != nil {
+		tx.Rollback() // Roll back the transaction on error.
+		return "", createErr
+	}
+
+	// Commit the transaction.
+	if commitErr := tx.Commit().Error; commitErr != nil {
+		return "", commitErr // Return an error if committing fails.
+	}
+
+	return room.RoomID.String(), nil
+}
+
+// endregion
+
+// region "Subscribe" adds the user to a channel as a read-only subscriber.
+func (s *roomService) Subscribe(ctx context.Context, userId string, roomId uuid.UUID) error {
+	if err := s.checkChannel(ctx, roomId); err != nil {
+		return err
+	}
+
+	return s.UserRoomService.AddMember(ctx, nil, userId, roomId)
+}
+
+// endregion
+
+// region "Unsubscribe" removes the user from a channel.
+func (s *roomService) Unsubscribe(ctx context.Context, userId string, roomId uuid.UUID) error {
+	if err := s.checkChannel(ctx, roomId); err != nil {
+		return err
+	}
+
+	return s.UserRoomService.Leave(ctx, userId, roomId)
+}
+
+// endregion
+
+// region "SetSlowMode" sets the minimum interval between messages of a member, restricted to room owners and admins.
+func (s *roomService) SetSlowMode(ctx context.Context, userId string, roomId uuid.UUID, seconds int) error {
+	if seconds < 0 || seconds > MaxSlowModeSeconds {
+		return ErrInvalidSlowMode
+	}
+
+	userRoom, err := s.UserRoomService.GetUserRoom(ctx, userId, roomId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotRoomMember
+		}
+		return err
+	}
+
+	if userRoom.Room == nil || userRoom.Room.RoomType == types.Private {
+		return ErrNotGroupRoom // Slow mode only makes sense for rooms with many members.
+	}
+
+	if userRoom.RoomRole != types.Owner && userRoom.RoomRole != types.Admin {
+		return ErrNotRoomAdmin
+	}
+
+	return s.RoomRepository.UpdateFields(ctx, nil, &models.Room{RoomID: roomId}, map[string]interface{}{
+		"slow_mode_seconds": seconds,
+	})
+}
+
+// endregion
+
+// region "DeleteRoom" soft-deletes a group room or channel with its memberships and messages, restricted to its owner.
+// It returns the emails of the former members so they can be notified.
+func (s *roomService) DeleteRoom(ctx context.Context, userId string, roomId uuid.UUID) ([]string, error) {
+	userRoom, err := s.UserRoomService.GetUserRoom(ctx, userId, roomId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRoomMember
+		}
+		return nil, err
+	}
+
+	if userRoom.Room == nil || userRoom.Room.RoomType == types.Private {
+		return nil, ErrNotGroupRoom // Private conversations are removed per user instead.
+	}
+
+	if userRoom.RoomRole != types.Owner {
+		return nil, ErrNotRoomOwner
+	}
+
+	// Collect the members before their memberships disappear.
+	memberEmails, emailsErr := s.UserRoomService.GetMemberEmails(ctx, roomId)
+	if emailsErr != nil {
+		return nil, emailsErr
+	}
+
+	// Begin a new database transaction.
+	tx := s.RoomRepository.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	if deleteErr := s.RoomRepository.SoftDeleteWithContents(ctx, tx, roomId); deleteErr != nil {
+		tx.Rollback() // Roll back the transaction on error.
+		return nil, deleteErr
+	}
+
+	// Commit the transaction.
+	if commitErr := tx.Commit().Error; commitErr != nil {
+		return nil, commitErr
+	}
+
+	return memberEmails, nil
+}
+
+// endregion
+
+// region "PurgeDeletedRooms" permanently removes rooms deleted longer than the grace period ago, including their S3 attachments.
+func (s *roomService) PurgeDeletedRooms(ctx context.Context, gracePeriod time.Duration) error {
+	roomIds, err := s.RoomRepository.GetDeletedRoomIDs(ctx, time.Now().UTC().Add(-gracePeriod))
+	if err != nil {
+		return err
+	}
+
+	for _, roomId := range roomIds {
+		if purgeErr := s.purgeRoom(ctx, roomId); purgeErr != nil {
+			// Keep going; the room will be retried on the next run.
+			logging.FromContext(ctx).Error("failed to purge room", "room_id", roomId, "error", purgeErr)
+		}
+	}
+
+	return nil
+}
+
+// endregion
+
+// region "purgeRoom" deletes a room's attachments from S3 and then its rows from the database.
+func (s *roomService) purgeRoom(ctx context.Context, roomId uuid.UUID) error {
+	attachmentURLs, err := s.RoomRepository.GetAttachmentURLs(ctx, roomId)
+	if err != nil {
+		return err
+	}
+
+	// Remove the files first: once the rows are gone their URLs are lost.
+	for _, attachmentURL := range attachmentURLs {
+		deleteErr := s.S3Service.DeleteFile(ctx, attachmentURL)
+		if errors.Is(deleteErr, ErrFileNotInBucket) {
+			// Nothing of ours to delete; retrying would never succeed.
+			logging.FromContext(ctx).Warn("skipping attachment outside the bucket", "room_id", roomId, "url", attachmentURL)
+			continue
+		}
+		if deleteErr != nil {
+			return deleteErr // Transient failure: keep the rows so the next run retries.
+		}
+	}
+
+	tx := s.RoomRepository.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	if deleteErr := s.RoomRepository.HardDeleteWithContents(ctx, tx, roomId); deleteErr != nil {
+		tx.Rollback()
+		return deleteErr
+	}
+
+	return tx.Commit().Error
+}
+
+// endregion
+
+// region "checkChannel" ensures the room exists and is a channel.
+func (s *roomService) checkChannel(ctx context.Context, roomId uuid.UUID) error {
+	room, err := s.GetById(ctx, roomId)
+	if err != nil {
+		return err
+	}
+
+	if room.RoomType != types.Channel {
+		return ErrNotChannelRoom
+	}
+
+	return nil
 }
 
 // endregion
@@ -2,17 +2,26 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mime/multipart"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/kwa0x2/swiftchat-backend/config"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
+	"github.com/kwa0x2/swiftchat-backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var ErrFileNotInBucket = errors.New("file is not stored in the bucket")
+
 type IS3Service interface {
-	UploadFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error)
+	UploadFile(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader) (string, error)
+	DeleteFile(ctx context.Context, fileURL string) error
 }
 
 type s3Service struct{}
@@ -22,7 +31,7 @@ func NewS3Service() IS3Service {
 }
 
 // region "UploadFile" uploads a file to S3 and returns the file URL
-func (s *s3Service) UploadFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
+func (s *s3Service) UploadFile(ctx context.Context, file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
 	// Generate a unique filename using the current Unix timestamp and the original filename.
 	fileName := fmt.Sprintf("%d_%s", time.Now().Unix(), fileHeader.Filename)
 
@@ -34,7 +43,10 @@ func (s *s3Service) UploadFile(file multipart.File, fileHeader *multipart.FileHe
 	}
 
 	// Upload the file to S3 using the PutObject method of the S3 client.
-	_, err := config.S3Client.PutObject(context.TODO(), params)
+	ctx, span := tracing.Start(ctx, "s3.PutObject", s3Attributes(fileName)...)
+	_, err := config.S3Client.PutObject(ctx, params)
+	tracing.End(span, err)
+	metrics.S3Operations.WithLabelValues("put_object", metrics.Outcome(err)).Inc()
 	if err != nil {
 		return "", err // Return an error if the upload fails.
 	}
@@ -45,3 +57,40 @@ func (s *s3Service) UploadFile(file multipart.File, fileHeader *multipart.FileHe
 }
 
 // endregion
+
+// region "DeleteFile" removes a file previously uploaded by UploadFile, identified by its URL; a file that is already gone counts as deleted
+func (s *s3Service) DeleteFile(ctx context.Context, fileURL string) error {
+	// Files are served from the bucket root, so the object key is everything after the bucket host.
+	prefix := fmt.Sprintf("https://%s.s3.amazonaws.com/", config.GetS3BucketName())
+	if !strings.HasPrefix(fileURL, prefix) {
+		return fmt.Errorf("%w: %s", ErrFileNotInBucket, fileURL)
+	}
+
+	key := strings.TrimPrefix(fileURL, prefix)
+	params := &s3.DeleteObjectInput{
+		Bucket: aws.String(config.GetS3BucketName()), // Specify the S3 bucket name.
+		Key:    aws.String(key),                      // Set the object key to delete.
+	}
+
+	ctx, span := tracing.Start(ctx, "s3.DeleteObject", s3Attributes(key)...)
+	_, err := config.S3Client.DeleteObject(ctx, params)
+	var noSuchKey *s3types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		err = nil // Already deleted, e.g. by an earlier purge that failed halfway.
+	}
+	tracing.End(span, err)
+	metrics.S3Operations.WithLabelValues("delete_object", metrics.Outcome(err)).Inc()
+	return err
+}
+
+// endregion
+
+// region "s3Attributes" returns the span attributes identifying an object in the bucket
+func s3Attributes(key string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("aws.s3.bucket", config.GetS3BucketName()),
+		attribute.String("aws.s3.key", key),
+	}
+}
+
+// endregion
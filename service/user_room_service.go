@@ -1,14 +1,43 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/repository"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrNotGroupRoom       = errors.New("this action is only available for group rooms and channels")
+	ErrNotRoomMember      = errors.New("user is not a member of the room")
+	ErrNotRoomAdmin       = errors.New("only room owners and admins can perform this action")
+	ErrAlreadyRoomMember  = errors.New("user is already a member of the room")
+	ErrBannedFromRoom     = errors.New("user is banned from the room")
+	ErrOwnerCannotLeave   = errors.New("the room owner cannot leave the room")
+	ErrCannotRemoveMember = errors.New("not allowed to remove this member")
+	ErrRoomDeleted        = errors.New("room has been deleted")
 )
 
 type IUserRoomService interface {
-	Create(tx *gorm.DB, userRoom *models.UserRoom) error
-	GetPrivateRoom(userId1, userId2 string) (string, error)
+	Create(ctx context.Context, tx *gorm.DB, userRoom *models.UserRoom) error
+	GetUserRoom(ctx context.Context, userId string, roomId uuid.UUID) (*models.UserRoom, error)
+	LockUserRoom(ctx context.Context, tx *gorm.DB, userId string, roomId uuid.UUID) (*models.UserRoom, error)
+	AddMember(ctx context.Context, tx *gorm.DB, userId string, roomId uuid.UUID) error
+	Leave(ctx context.Context, userId string, roomId uuid.UUID) error
+	RemoveMember(ctx context.Context, actorUserId, targetUserId string, roomId uuid.UUID, ban bool) error
+	GetMembers(ctx context.Context, userId string, roomId uuid.UUID, page, limit int) ([]*models.UserRoom, int64, error)
+	GetMutedMember(ctx context.Context, roomId uuid.UUID, userEmail string) (*models.UserRoom, error)
+	Mute(ctx context.Context, userId string, roomId uuid.UUID, mutedUntil *time.Time) error
+	Archive(ctx context.Context, userId string, roomId uuid.UUID, archived bool) error
+	Pin(ctx context.Context, userId string, roomId uuid.UUID, pinned bool) error
+	GetRoomIDsByType(ctx context.Context, userId string, roomType types.RoomType) ([]uuid.UUID, error)
+	GetMemberEmails(ctx context.Context, roomId uuid.UUID) ([]string, error)
+	ClearHistory(ctx context.Context, userId string, roomId uuid.UUID) error
+	RemoveFromChatList(ctx context.Context, userId string, roomId uuid.UUID) error
 }
 
 type userRoomService struct {
@@ -22,15 +51,261 @@ func NewUserRoomService(UserRoomRepository repository.IUserRoomRepository) IUser
 }
 
 // region "Create" adds a new user room to the database
-func (s *userRoomService) Create(tx *gorm.DB, userRoom *models.UserRoom) error {
-	return s.UserRoomRepository.Create(tx, userRoom)
+func (s *userRoomService) Create(ctx context.Context, tx *gorm.DB, userRoom *models.UserRoom) error {
+	return s.UserRoomRepository.Create(ctx, tx, userRoom)
+}
+
+//endregion
+
+// region "GetUserRoom" retrieves the membership of a user in a room
+func (s *userRoomService) GetUserRoom(ctx context.Context, userId string, roomId uuid.UUID) (*models.UserRoom, error) {
+	return s.UserRoomRepository.GetUserRoom(ctx, &models.UserRoom{UserID: userId, RoomID: roomId}, false)
+}
+
+//endregion
+
+// region "LockUserRoom" retrieves the membership of a user in a room, locking it until the transaction ends
+func (s *userRoomService) LockUserRoom(ctx context.Context, tx *gorm.DB, userId string, roomId uuid.UUID) (*models.UserRoom, error) {
+	return s.UserRoomRepository.LockUserRoom(ctx, tx, &models.UserRoom{UserID: userId, RoomID: roomId})
+}
+
+//endregion
+
+// region "AddMember" adds a user to a group room, restoring a previous membership unless the user was banned
+func (s *userRoomService) AddMember(ctx context.Context, tx *gorm.DB, userId string, roomId uuid.UUID) error {
+	whereUserRoom := &models.UserRoom{
+		UserID: userId, // The joining user.
+		RoomID: roomId, // The room to join.
+	}
+
+	exists, err := s.UserRoomRepository.RoomExists(ctx, tx, roomId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRoomDeleted // Invites and links can outlive the room.
+	}
+
+	// Look for any previous membership, including ones that were left or removed.
+	existing, err := s.UserRoomRepository.GetUserRoom(ctx, whereUserRoom, true)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if existing == nil {
+		// First time joining: create a regular membership.
+		userRoom := &models.UserRoom{
+			UserID:   userId,       // Assign the user ID.
+			RoomID:   roomId,       // Assign the room ID.
+			RoomRole: types.Member, // New members start as regular members.
+		}
+		return s.Create(ctx, tx, userRoom)
+	}
+
+	if existing.BannedAt != nil {
+		return ErrBannedFromRoom // Banned users can never rejoin.
+	}
+
+	if !existing.DeletedAt.Valid {
+		return ErrAlreadyRoomMember
+	}
+
+	return s.UserRoomRepository.Restore(ctx, tx, whereUserRoom)
+}
+
+//endregion
+
+// region "Leave" removes the user from a group room
+func (s *userRoomService) Leave(ctx context.Context, userId string, roomId uuid.UUID) error {
+	userRoom, err := s.getGroupMembership(ctx, userId, roomId)
+	if err != nil {
+		return err
+	}
+
+	if userRoom.RoomRole == types.Owner {
+		return ErrOwnerCannotLeave
+	}
+
+	return s.UserRoomRepository.Delete(ctx, nil, &models.UserRoom{UserID: userId, RoomID: roomId})
+}
+
+//endregion
+
+// region "RemoveMember" kicks a member from a group room, optionally banning them from rejoining
+func (s *userRoomService) RemoveMember(ctx context.Context, actorUserId, targetUserId string, roomId uuid.UUID, ban bool) error {
+	actor, err := s.getGroupMembership(ctx, actorUserId, roomId)
+	if err != nil {
+		return err
+	}
+
+	if actor.RoomRole != types.Owner && actor.RoomRole != types.Admin {
+		return ErrNotRoomAdmin
+	}
+
+	target, targetErr := s.getGroupMembership(ctx, targetUserId, roomId)
+	if targetErr != nil {
+		return targetErr
+	}
+
+	// Nobody can remove themselves or the owner, and admins cannot remove other admins.
+	if actorUserId == targetUserId || target.RoomRole == types.Owner || (target.RoomRole == types.Admin && actor.RoomRole != types.Owner) {
+		return ErrCannotRemoveMember
+	}
+
+	whereUserRoom := &models.UserRoom{
+		UserID: targetUserId, // The member being removed.
+		RoomID: roomId,       // The room they are removed from.
+	}
+
+	tx := s.UserRoomRepository.GetDB().WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// Mark the membership as banned before removing it so rejoining is refused.
+	if ban {
+		now := time.Now().UTC()
+		if banErr := s.UserRoomRepository.Update(ctx, tx, whereUserRoom, &models.UserRoom{BannedAt: &now}); banErr != nil {
+			tx.Rollback()
+			return banErr
+		}
+	}
+
+	if deleteErr := s.UserRoomRepository.Delete(ctx, tx, whereUserRoom); deleteErr != nil {
+		tx.Rollback()
+		return deleteErr
+	}
+
+	return tx.Commit().Error
 }
 
 //endregion
 
-// region "GetPrivateRoom" fetches the room ID of a private room for the specified user IDs
-func (s *userRoomService) GetPrivateRoom(userId1, userId2 string) (string, error) {
-	return s.UserRoomRepository.GetPrivateRoom(userId1, userId2)
+// region "GetMembers" retrieves a page of a room's members, restricted to members of that room
+func (s *userRoomService) GetMembers(ctx context.Context, userId string, roomId uuid.UUID, page, limit int) ([]*models.UserRoom, int64, error) {
+	// Only members may see who else is in the room.
+	if _, err := s.GetUserRoom(ctx, userId, roomId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, ErrNotRoomMember
+		}
+		return nil, 0, err
+	}
+
+	return s.UserRoomRepository.GetMembers(ctx, roomId, limit, (page-1)*limit)
+}
+
+//endregion
+
+// region "GetMutedMember" retrieves the membership of a room's member, identified by email, if they currently mute the room
+func (s *userRoomService) GetMutedMember(ctx context.Context, roomId uuid.UUID, userEmail string) (*models.UserRoom, error) {
+	return s.UserRoomRepository.GetMutedMember(ctx, roomId, userEmail)
+}
+
+//endregion
+
+// region "Mute" mutes a room's notifications for the user until the given time, or unmutes it when nil
+func (s *userRoomService) Mute(ctx context.Context, userId string, roomId uuid.UUID, mutedUntil *time.Time) error {
+	return s.UserRoomRepository.UpdateFields(ctx, nil, &models.UserRoom{UserID: userId, RoomID: roomId}, map[string]interface{}{
+		"mutedUntil": mutedUntil,
+	})
+}
+
+//endregion
+
+// region "Archive" archives or unarchives a room for the user
+func (s *userRoomService) Archive(ctx context.Context, userId string, roomId uuid.UUID, archived bool) error {
+	return s.UserRoomRepository.UpdateFields(ctx, nil, &models.UserRoom{UserID: userId, RoomID: roomId}, map[string]interface{}{
+		"archived": archived,
+	})
+}
+
+//endregion
+
+// region "Pin" pins a room after the user's other pinned rooms, or unpins it
+func (s *userRoomService) Pin(ctx context.Context, userId string, roomId uuid.UUID, pinned bool) error {
+	var pinnedOrder *int
+	if pinned {
+		maxOrder, err := s.UserRoomRepository.GetMaxPinnedOrder(ctx, userId)
+		if err != nil {
+			return err
+		}
+		nextOrder := maxOrder + 1
+		pinnedOrder = &nextOrder // Newly pinned rooms go after the existing ones.
+	}
+
+	return s.UserRoomRepository.UpdateFields(ctx, nil, &models.UserRoom{UserID: userId, RoomID: roomId}, map[string]interface{}{
+		"pinned_order": pinnedOrder,
+	})
+}
+
+//endregion
+
+// region "GetRoomIDsByType" retrieves the IDs of the rooms of a given type the user is a member of
+func (s *userRoomService) GetRoomIDsByType(ctx context.Context, userId string, roomType types.RoomType) ([]uuid.UUID, error) {
+	return s.UserRoomRepository.GetRoomIDsByType(ctx, userId, roomType)
+}
+
+//endregion
+
+// region "GetMemberEmails" retrieves the emails of all active members of a room
+func (s *userRoomService) GetMemberEmails(ctx context.Context, roomId uuid.UUID) ([]string, error) {
+	return s.UserRoomRepository.GetMemberEmails(ctx, roomId)
+}
+
+//endregion
+
+// region "getGroupMembership" retrieves an active membership and ensures the room is a group or a channel
+func (s *userRoomService) getGroupMembership(ctx context.Context, userId string, roomId uuid.UUID) (*models.UserRoom, error) {
+	userRoom, err := s.GetUserRoom(ctx, userId, roomId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotRoomMember
+		}
+		return nil, err
+	}
+
+	if userRoom.Room == nil || (userRoom.Room.RoomType != types.Group && userRoom.Room.RoomType != types.Channel) {
+		return nil, ErrNotGroupRoom
+	}
+
+	return userRoom, nil
+}
+
+//endregion
+
+// region "ClearHistory" hides all current messages of a room from the given user only
+func (s *userRoomService) ClearHistory(ctx context.Context, userId string, roomId uuid.UUID) error {
+	now := time.Now().UTC()
+
+	whereUserRoom := &models.UserRoom{
+		UserID: userId, // The user clearing the chat.
+		RoomID: roomId, // The room to clear.
+	}
+
+	updateUserRoom := &models.UserRoom{
+		ClearedAt: &now, // Move the history watermark to now.
+	}
+
+	return s.UserRoomRepository.Update(ctx, nil, whereUserRoom, updateUserRoom)
+}
+
+//endregion
+
+// region "RemoveFromChatList" clears a room's history for the given user and hides it from their chat list until a new message arrives
+func (s *userRoomService) RemoveFromChatList(ctx context.Context, userId string, roomId uuid.UUID) error {
+	now := time.Now().UTC()
+
+	whereUserRoom := &models.UserRoom{
+		UserID: userId, // The user deleting the conversation.
+		RoomID: roomId, // The room to delete.
+	}
+
+	updateUserRoom := &models.UserRoom{
+		ClearedAt: &now, // Move the history watermark to now.
+		HiddenAt:  &now, // Hide the room until a newer message arrives.
+	}
+
+	return s.UserRoomRepository.Update(ctx, nil, whereUserRoom, updateUserRoom)
 }
 
 //endregion
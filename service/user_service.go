@@ -1,19 +1,40 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/repository"
+	"github.com/kwa0x2/swiftchat-backend/types"
+	"time"
+)
+
+const (
+	MaxStatusTextLength  = 100 // Longest custom status message a user can set.
+	MaxStatusEmojiLength = 16  // Longest custom status emoji a user can set, in characters.
+)
+
+var (
+	ErrInvalidPresenceVisibility = errors.New("presence visibility must be one of everyone, friends or nobody")
+	ErrInvalidStatus             = errors.New("status must be one of available, busy, away or dnd")
+	ErrStatusTextTooLong         = errors.New("status text is too long")
+	ErrStatusEmojiTooLong        = errors.New("status emoji is too long")
+	ErrStatusExpiryInPast        = errors.New("status expiry must be in the future")
 )
 
 type IUserService interface {
-	IsUsernameUnique(userName string) bool
-	IsIdUnique(userId string) bool
-	IsEmailExists(email string) bool
-	Create(user *models.User) (*models.User, error)
-	GetByEmail(userEmail string) (*models.User, error)
-	GetUserById(userId string) (*models.User, error)
-	UpdateUserNameByMail(userName, userEmail string) error
-	UpdateUserPhotoByMail(userPhoto, userEmail string) error
+	IsUsernameUnique(ctx context.Context, userName string) bool
+	IsIdUnique(ctx context.Context, userId string) bool
+	IsEmailExists(ctx context.Context, email string) bool
+	Create(ctx context.Context, user *models.User) (*models.User, error)
+	GetByEmail(ctx context.Context, userEmail string) (*models.User, error)
+	GetUserById(ctx context.Context, userId string) (*models.User, error)
+	UpdateUserNameByMail(ctx context.Context, userName, userEmail string) error
+	UpdateUserPhotoByMail(ctx context.Context, userPhoto, userEmail string) error
+	UpdateLastSeenByMail(ctx context.Context, lastSeenAt time.Time, userEmail string) error
+	UpdatePresenceVisibilityByMail(ctx context.Context, visibility types.PresenceVisibility, userEmail string) error
+	UpdateStatusByMail(ctx context.Context, status types.UserStatus, statusText, statusEmoji *string, expiresAt *time.Time, userEmail string) error
+	ResetExpiredStatuses(ctx context.Context) ([]string, error)
 }
 
 type userService struct {
@@ -27,49 +48,49 @@ func NewUserService(userRepo repository.IUserRepository) IUserService {
 }
 
 // region "IsUsernameUnique" checks if the given username is unique
-func (s *userService) IsUsernameUnique(userName string) bool {
-	return s.UserRepository.IsFieldUnique(&models.User{UserName: userName})
+func (s *userService) IsUsernameUnique(ctx context.Context, userName string) bool {
+	return s.UserRepository.IsFieldUnique(ctx, &models.User{UserName: userName})
 }
 
 // endregion
 
 // region "IsIdUnique" checks if the given user ID is unique
-func (s *userService) IsIdUnique(userId string) bool {
-	return s.UserRepository.IsFieldUnique(&models.User{UserID: userId})
+func (s *userService) IsIdUnique(ctx context.Context, userId string) bool {
+	return s.UserRepository.IsFieldUnique(ctx, &models.User{UserID: userId})
 }
 
 // endregion
 
 // region "IsEmailExists" checks if the given email already exists in the database
-func (s *userService) IsEmailExists(email string) bool {
-	return s.UserRepository.IsFieldExists(&models.User{UserEmail: email})
+func (s *userService) IsEmailExists(ctx context.Context, email string) bool {
+	return s.UserRepository.IsFieldExists(ctx, &models.User{UserEmail: email})
 }
 
 // endregion
 
 // region "Create" adds a new user to the database
-func (s *userService) Create(user *models.User) (*models.User, error) {
-	return s.UserRepository.Create(user)
+func (s *userService) Create(ctx context.Context, user *models.User) (*models.User, error) {
+	return s.UserRepository.Create(ctx, user)
 }
 
 // endregion
 
 // region "GetByEmail" retrieves a user from the database by their email
-func (s *userService) GetByEmail(userEmail string) (*models.User, error) {
-	return s.UserRepository.GetUser(&models.User{UserEmail: userEmail})
+func (s *userService) GetByEmail(ctx context.Context, userEmail string) (*models.User, error) {
+	return s.UserRepository.GetUser(ctx, &models.User{UserEmail: userEmail})
 }
 
 // endregion
 
 // region "GetUserById" retrieves a user from the database by their ID
-func (s *userService) GetUserById(userId string) (*models.User, error) {
-	return s.UserRepository.GetUser(&models.User{UserID: userId})
+func (s *userService) GetUserById(ctx context.Context, userId string) (*models.User, error) {
+	return s.UserRepository.GetUser(ctx, &models.User{UserID: userId})
 }
 
 // endregion
 
 // region "UpdateUserNameByMail" updates the user's name based on their email
-func (s *userService) UpdateUserNameByMail(userName, userEmail string) error {
+func (s *userService) UpdateUserNameByMail(ctx context.Context, userName, userEmail string) error {
 	whereUser := &models.User{
 		UserEmail: userEmail, // User to find based on email.
 	}
@@ -77,13 +98,13 @@ func (s *userService) UpdateUserNameByMail(userName, userEmail string) error {
 		UserName: userName, // New username to set.
 	}
 
-	return s.UserRepository.Update(whereUser, updates)
+	return s.UserRepository.Update(ctx, whereUser, updates)
 }
 
 // endregion
 
 // region "UpdateUserPhotoByMail" updates the user's photo based on their email
-func (s *userService) UpdateUserPhotoByMail(userPhoto, userEmail string) error {
+func (s *userService) UpdateUserPhotoByMail(ctx context.Context, userPhoto, userEmail string) error {
 	whereUser := &models.User{
 		UserEmail: userEmail, // User to find based on email.
 	}
@@ -91,7 +112,79 @@ func (s *userService) UpdateUserPhotoByMail(userPhoto, userEmail string) error {
 		UserPhoto: userPhoto, // New photo URL to set.
 	}
 
-	return s.UserRepository.Update(whereUser, updates)
+	return s.UserRepository.Update(ctx, whereUser, updates)
+}
+
+// endregion
+
+// region "UpdateLastSeenByMail" records when the user was last connected based on their email
+func (s *userService) UpdateLastSeenByMail(ctx context.Context, lastSeenAt time.Time, userEmail string) error {
+	whereUser := &models.User{
+		UserEmail: userEmail, // User to find based on email.
+	}
+	updates := &models.User{
+		LastSeenAt: &lastSeenAt, // Time the user's last socket disconnected.
+	}
+
+	return s.UserRepository.Update(ctx, whereUser, updates)
+}
+
+// endregion
+
+// region "UpdatePresenceVisibilityByMail" updates who can see the user's presence based on their email
+func (s *userService) UpdatePresenceVisibilityByMail(ctx context.Context, visibility types.PresenceVisibility, userEmail string) error {
+	switch visibility {
+	case types.VisibleToEveryone, types.VisibleToFriends, types.VisibleToNobody:
+	default:
+		return ErrInvalidPresenceVisibility
+	}
+
+	whereUser := &models.User{
+		UserEmail: userEmail, // User to find based on email.
+	}
+	updates := &models.User{
+		PresenceVisibility: visibility, // New presence visibility to set.
+	}
+
+	return s.UserRepository.Update(ctx, whereUser, updates)
+}
+
+// endregion
+
+// region "UpdateStatusByMail" sets the user's availability and custom status based on their email
+func (s *userService) UpdateStatusByMail(ctx context.Context, status types.UserStatus, statusText, statusEmoji *string, expiresAt *time.Time, userEmail string) error {
+	switch status {
+	case types.Available, types.Busy, types.Away, types.DoNotDisturb:
+	default:
+		return ErrInvalidStatus
+	}
+
+	if statusText != nil && len([]rune(*statusText)) > MaxStatusTextLength {
+		return ErrStatusTextTooLong
+	}
+
+	if statusEmoji != nil && len([]rune(*statusEmoji)) > MaxStatusEmojiLength {
+		return ErrStatusEmojiTooLong
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return ErrStatusExpiryInPast
+	}
+
+	// Nil values are written too, so setting a new status clears the previous text, emoji and expiry.
+	return s.UserRepository.UpdateFields(ctx, &models.User{UserEmail: userEmail}, map[string]interface{}{
+		"status":          status,
+		"status_text":     statusText,
+		"status_emoji":    statusEmoji,
+		"statusExpiresAt": expiresAt,
+	})
+}
+
+// endregion
+
+// region "ResetExpiredStatuses" resets every expired status to available and returns the emails of the affected users
+func (s *userService) ResetExpiredStatuses(ctx context.Context) ([]string, error) {
+	return s.UserRepository.ResetExpiredStatuses(ctx, time.Now().UTC())
 }
 
 // endregion
@@ -1,91 +1,196 @@
 package adapter
 
 import (
-	"fmt"
+	"context"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/config"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
+	"github.com/kwa0x2/swiftchat-backend/internal/tracing"
+	"github.com/kwa0x2/swiftchat-backend/models"
 	"github.com/kwa0x2/swiftchat-backend/service"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
 	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
+	"github.com/kwa0x2/swiftchat-backend/types"
 	"github.com/zishang520/socket.io/socket"
+	"go.opentelemetry.io/otel/attribute"
+	"log/slog"
 	"sync"
+	"time"
 )
 
 type ISocketAdapter interface {
 	HandleConnection()
-	EmitToFriendsAndSentRequests(event, userEmail string, emitData interface{}) error
+	EmitToFriendsAndSentRequests(ctx context.Context, event, userEmail string, emitData interface{}) error
+	IsUserOnline(userEmail string) bool
+	IsPresenceVisible(ctx context.Context, viewerEmail string, user *models.User) bool
+	GetPresence(ctx context.Context, viewerEmail string, user *models.User) *Presence
+	GetPresences(ctx context.Context, viewerEmail string, users []*models.User) map[string]*Presence
+	SendMessage(ctx context.Context, messageObj *models.Message, senderMail, receiverMail string) (string, error)
+	EditMessage(ctx context.Context, connectedUserID, editedMessage string, messageId uuid.UUID) error
+	DeleteMessage(ctx context.Context, connectedUserID string, messageId uuid.UUID) error
+	DeleteMessageForMe(ctx context.Context, connectedUserID, connectedUserMail string, messageId uuid.UUID) error
+	CheckRateLimit(userEmail, event string) error
 }
 
+// region Presence represents the online status, last-seen time and custom status of a user as seen by another user.
+type Presence struct {
+	UserEmail       string           `json:"user_email"`
+	Online          bool             `json:"online"`
+	Idle            bool             `json:"idle"` // Online, but no heartbeat received for a while
+	LastSeenAt      *time.Time       `json:"lastSeenAt"`
+	Status          types.UserStatus `json:"status,omitempty"`
+	StatusText      *string          `json:"status_text,omitempty"`
+	StatusEmoji     *string          `json:"status_emoji,omitempty"`
+	StatusExpiresAt *time.Time       `json:"statusExpiresAt,omitempty"`
+}
+
+// endregion
+
 type socketAdapter struct {
-	Gateway          gateway.ISocketGateway
-	onlineUserEmails []string
-	MessageService   service.IMessageService
-	FriendService    service.IFriendService
-	RequestService   service.IRequestService
-	mux              sync.RWMutex
+	Gateway                gateway.ISocketGateway
+	PresenceStore          cluster.IPresenceStore
+	EventLog               cluster.IEventLog
+	RateLimiter            *rateLimiter
+	MaxRateLimitViolations int             // Throttled events per minute after which a socket is disconnected
+	ShutdownCtx            context.Context // Cancelled when the app shuts down, stopping the background watcher
+	onlineUsers            map[string]int  // Number of sockets connected to this instance per user email, guarded by mux
+	MessageService         service.IMessageService
+	FriendService          service.IFriendService
+	RequestService         service.IRequestService
+	UserRoomService        service.IUserRoomService
+	UserService            service.IUserService
+	mux                    sync.RWMutex
 }
 
-func NewSocketAdapter(gateway gateway.ISocketGateway, messageService service.IMessageService, friendService service.IFriendService, requestService service.IRequestService) ISocketAdapter {
+func NewSocketAdapter(shutdownCtx context.Context, gateway gateway.ISocketGateway, messageService service.IMessageService, friendService service.IFriendService, requestService service.IRequestService, userRoomService service.IUserRoomService, userService service.IUserService, presenceStore cluster.IPresenceStore, eventLog cluster.IEventLog, rateLimits map[string]config.RateLimit, maxRateLimitViolations int) ISocketAdapter {
 	return &socketAdapter{
-		Gateway:        gateway,
-		MessageService: messageService,
-		FriendService:  friendService,
-		RequestService: requestService,
+		Gateway:                gateway,
+		onlineUsers:            make(map[string]int),
+		MessageService:         messageService,
+		FriendService:          friendService,
+		RequestService:         requestService,
+		UserRoomService:        userRoomService,
+		UserService:            userService,
+		PresenceStore:          presenceStore,
+		EventLog:               eventLog,
+		RateLimiter:            newRateLimiter(rateLimits),
+		MaxRateLimitViolations: maxRateLimitViolations,
+		ShutdownCtx:            shutdownCtx,
 	}
 }
 
 // region "HandleConnection" manages user connections
 func (adapter *socketAdapter) HandleConnection() {
+	go adapter.watchIdleUsers(adapter.ShutdownCtx) // Flag idle users, announce users of crashed instances as offline and expire statuses
+
 	adapter.Gateway.OnConnection(func(socketio *socket.Socket) {
-		ctx := socketio.Request().Context()
-		connectedUserID := ctx.Value("id").(string)
-		connectedUserMail := ctx.Value("email").(string)
-		fmt.Println(connectedUserMail, " is  online")
+		requestCtx := socketio.Request().Context()
+		connectedUserID := requestCtx.Value("id").(string)
+		connectedUserMail := requestCtx.Value("email").(string)
+		socketId := string(socketio.Id())
+		metrics.SocketConnections.Inc()
 
-		if !adapter.emailExists(connectedUserMail) {
-			adapter.onlineUserEmails = append(adapter.onlineUserEmails, connectedUserMail)
+		// The socket outlives the handshake request, so keep its values but not its cancellation.
+		// Every log of this socket carries its ID, the user's ID and the ID of the request that opened it.
+		logger := logging.FromContext(requestCtx).With("socket_id", socketId, "user_id", connectedUserID)
+		socketCtx := logging.WithLogger(context.WithoutCancel(requestCtx), logger)
+		logger.Debug("socket connected")
+
+		ctx, span := tracing.StartLinked(socketCtx, "socket connect", socketAttributes(socketId, connectedUserID)...)
+
+		// Join the user's private rooms so membership changes and notifications can reach all of their sockets.
+		adapter.Gateway.JoinRoom(socketio, gateway.UserRoom(connectedUserID))
+		adapter.Gateway.JoinRoom(socketio, gateway.NotificationRoom(connectedUserMail))
+
+		// Join the broadcast rooms of the user's channels so posts reach them with a single emit.
+		if channelIds, err := adapter.UserRoomService.GetRoomIDsByType(ctx, connectedUserID, types.Channel); err == nil {
+			for _, channelId := range channelIds {
+				adapter.Gateway.JoinRoom(socketio, gateway.ChannelRoom(channelId.String()))
+			}
 		}
 
-		adapter.Gateway.Emit("onlineUsers", adapter.onlineUserEmails) // Broadcast online users
+		if adapter.addConnection(logger, connectedUserMail, socketId) {
+			logger.Info("user online")
+			adapter.emitPresence(ctx, connectedUserMail, true) // Only the user's friends learn they came online
+		}
+
+		// Send the new socket which of the user's friends are online.
+		if onlineFriends, err := adapter.getOnlineFriends(ctx, connectedUserMail); err == nil {
+			socketio.Emit("onlineUsers", onlineFriends)
+		}
+		span.End()
 
 		socketio.On("disconnect", func(...any) {
-			adapter.handleDisconnect(connectedUserMail)
-		})
+			metrics.SocketConnections.Dec()
 
-		socketio.On("joinRoom", func(roomData ...any) {
-			adapter.handleJoinRoom(socketio, roomData...)
+			ctx, span := tracing.StartLinked(socketCtx, "socket disconnect", socketAttributes(socketId, connectedUserID)...)
+			defer span.End()
+			adapter.handleDisconnect(ctx, connectedUserMail, socketId)
 		})
 
-		socketio.On("sendMessage", func(args ...any) {
-			adapter.handleSendMessage(connectedUserID, connectedUserMail, args...)
-		})
+		// Throttle every event per user, counting this socket's violations separately.
+		violations := &socketViolations{}
 
-		socketio.On("deleteMessage", func(args ...any) {
-			adapter.handleDeleteMessage(args...)
-		})
+		socketio.On("joinRoom", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "joinRoom", violations, func(ctx context.Context, roomData ...any) {
+			adapter.handleJoinRoom(ctx, socketio, connectedUserID, connectedUserMail, roomData...)
+		}))
 
-		socketio.On("editMessage", func(args ...any) {
-			adapter.handleEditMessage(args...)
-		})
+		socketio.On("sendMessage", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "sendMessage", violations, func(ctx context.Context, args ...any) {
+			adapter.handleSendMessage(ctx, connectedUserID, connectedUserMail, args...)
+		}))
 
-		socketio.On("updateMessageStarred", func(args ...any) {
-			adapter.handleUpdateMessageStarred(args...)
-		})
+		socketio.On("deleteMessage", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "deleteMessage", violations, func(ctx context.Context, args ...any) {
+			adapter.handleDeleteMessage(ctx, connectedUserID, connectedUserMail, args...)
+		}))
 
-		socketio.On("readMessage", func(args ...any) {
-			adapter.handleReadMessage(connectedUserID, args...)
-		})
+		socketio.On("editMessage", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "editMessage", violations, func(ctx context.Context, args ...any) {
+			adapter.handleEditMessage(ctx, connectedUserID, args...)
+		}))
+
+		socketio.On("updateMessageStarred", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "updateMessageStarred", violations, func(ctx context.Context, args ...any) {
+			adapter.handleUpdateMessageStarred(ctx, args...)
+		}))
+
+		socketio.On("readMessage", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "readMessage", violations, func(ctx context.Context, args ...any) {
+			adapter.handleReadMessage(ctx, connectedUserID, args...)
+		}))
+
+		socketio.On("setStatus", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "setStatus", violations, func(ctx context.Context, args ...any) {
+			adapter.handleSetStatus(ctx, connectedUserMail, args...)
+		}))
+
+		socketio.On("sync", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "sync", violations, func(ctx context.Context, args ...any) {
+			adapter.handleSync(ctx, connectedUserID, args...)
+		}))
+
+		socketio.On("heartbeat", adapter.limited(socketio, socketCtx, connectedUserID, connectedUserMail, "heartbeat", violations, func(ctx context.Context, _ ...any) {
+			adapter.handleHeartbeat(ctx, connectedUserMail)
+		}))
 	})
 }
 
 // endregion
 
+// region "socketAttributes" returns the span attributes identifying a socket and its user
+func socketAttributes(socketId, userId string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("socket.id", socketId),
+		attribute.String("user.id", userId),
+	}
+}
+
+// endregion
+
 // region "EmitToFriendsAndSentRequests" sends an event to all friends and sent requests of the specified user with the provided data.
-func (adapter *socketAdapter) EmitToFriendsAndSentRequests(event, userEmail string, emitData interface{}) error {
+func (adapter *socketAdapter) EmitToFriendsAndSentRequests(ctx context.Context, event, userEmail string, emitData interface{}) error {
 	// Retrieve the list of friends for the given userEmail.
-	friends, err := adapter.FriendService.GetFriends(userEmail, true)
+	friends, err := adapter.FriendService.GetFriends(ctx, userEmail, true)
 	if err != nil {
 		return err
 	}
 
-	requests, ReqErr := adapter.RequestService.GetSentRequests(userEmail)
+	requests, ReqErr := adapter.RequestService.GetSentRequests(ctx, userEmail)
 	if ReqErr != nil {
 		return ReqErr
 	}
@@ -109,7 +214,7 @@ func (adapter *socketAdapter) EmitToFriendsAndSentRequests(event, userEmail stri
 		go func(email string) {
 			defer wg.Done() // Decrement the counter when the goroutine completes.
 			// Emit the event to the friend's notification room with the provided data.
-			adapter.Gateway.EmitToNotificationRoom(event, email, emitData)
+			adapter.Gateway.EmitToNotificationRoom(ctx, event, email, emitData)
 		}(email) // Pass the unique email to the goroutine.
 	}
 	wg.Wait() // Wait for all goroutines to finish.
@@ -119,14 +224,259 @@ func (adapter *socketAdapter) EmitToFriendsAndSentRequests(event, userEmail stri
 
 // endregion
 
-// region "emailExists" checks if an email is already in the online user list
-func (adapter *socketAdapter) emailExists(email string) bool {
-	for _, existingEmail := range adapter.onlineUserEmails {
-		if existingEmail == email {
-			return true
+// region "IsUserOnline" reports whether the user with the given email has a connected socket on any instance
+func (adapter *socketAdapter) IsUserOnline(userEmail string) bool {
+	online, err := adapter.PresenceStore.IsOnline(userEmail)
+	if err == nil {
+		return online
+	}
+
+	// Fall back to this instance's sockets while Redis is unreachable.
+	adapter.mux.RLock()
+	defer adapter.mux.RUnlock()
+
+	return adapter.onlineUsers[userEmail] > 0
+}
+
+// endregion
+
+// region "addConnection" counts a new socket of the user and reports whether it is the user's first one across all instances
+func (adapter *socketAdapter) addConnection(logger *slog.Logger, email, socketId string) bool {
+	adapter.mux.Lock()
+	adapter.onlineUsers[email]++
+	isFirstLocal := adapter.onlineUsers[email] == 1
+	adapter.mux.Unlock()
+
+	isFirst, err := adapter.PresenceStore.AddConnection(email, socketId)
+	if err != nil {
+		logger.Error("failed to store presence", "error", err)
+		return isFirstLocal
+	}
+	return isFirst
+}
+
+// endregion
+
+// region "removeConnection" uncounts a closed socket of the user and reports whether it was the user's last one across all instances
+func (adapter *socketAdapter) removeConnection(logger *slog.Logger, email, socketId string) bool {
+	adapter.mux.Lock()
+	isLastLocal := adapter.onlineUsers[email] <= 1
+	if isLastLocal {
+		delete(adapter.onlineUsers, email)
+	} else {
+		adapter.onlineUsers[email]--
+	}
+	adapter.mux.Unlock()
+
+	isLast, err := adapter.PresenceStore.RemoveConnection(email, socketId)
+	if err != nil {
+		logger.Error("failed to remove presence", "error", err)
+		return isLastLocal
+	}
+	return isLast
+}
+
+// endregion
+
+// region "IsPresenceVisible" reports whether the viewer may see the user's online status and last-seen time
+func (adapter *socketAdapter) IsPresenceVisible(ctx context.Context, viewerEmail string, user *models.User) bool {
+	if viewerEmail == user.UserEmail {
+		return true
+	}
+
+	isFriend := false
+	if user.PresenceVisibility == types.VisibleToFriends {
+		friend, err := adapter.FriendService.GetSpecificFriend(ctx, viewerEmail, user.UserEmail)
+		isFriend = err == nil && friend != nil && friend.FriendStatus == types.Friend
+	}
+	return adapter.isPresenceVisibleTo(viewerEmail, user, isFriend)
+}
+
+// endregion
+
+// region "isPresenceVisibleTo" applies the user's privacy setting once it is known whether the viewer is their friend
+func (adapter *socketAdapter) isPresenceVisibleTo(viewerEmail string, user *models.User, isFriend bool) bool {
+	if viewerEmail == user.UserEmail {
+		return true
+	}
+
+	switch user.PresenceVisibility {
+	case types.VisibleToNobody:
+		return false
+	case types.VisibleToFriends:
+		return isFriend
+	default:
+		return true
+	}
+}
+
+// endregion
+
+// region "GetPresence" returns the user's presence as seen by the viewer, hiding it when the user's privacy setting does not allow it
+func (adapter *socketAdapter) GetPresence(ctx context.Context, viewerEmail string, user *models.User) *Presence {
+	if !adapter.IsPresenceVisible(ctx, viewerEmail, user) {
+		return &Presence{UserEmail: user.UserEmail}
+	}
+
+	online := adapter.IsUserOnline(user.UserEmail)
+	return adapter.buildPresence(user, online, online && adapter.isIdle(ctx, user.UserEmail))
+}
+
+// endregion
+
+// region "GetPresences" returns the presence of each user as seen by the viewer, keyed by email, with one friend query and one Redis round trip
+func (adapter *socketAdapter) GetPresences(ctx context.Context, viewerEmail string, users []*models.User) map[string]*Presence {
+	logger := logging.FromContext(ctx)
+
+	// Only users sharing their presence with friends need the friendship checked.
+	var friendCandidates []string
+	for _, user := range users {
+		if user.UserEmail != viewerEmail && user.PresenceVisibility == types.VisibleToFriends {
+			friendCandidates = append(friendCandidates, user.UserEmail)
+		}
+	}
+
+	friends := make(map[string]bool, len(friendCandidates))
+	friendEmails, err := adapter.FriendService.GetFriendEmailsAmong(ctx, viewerEmail, friendCandidates)
+	if err != nil {
+		logger.Error("failed to get friends for presence", "error", err)
+	}
+	for _, friendEmail := range friendEmails {
+		friends[friendEmail] = true
+	}
+
+	visibleEmails := make([]string, 0, len(users))
+	for _, user := range users {
+		if adapter.isPresenceVisibleTo(viewerEmail, user, friends[user.UserEmail]) {
+			visibleEmails = append(visibleEmails, user.UserEmail)
+		}
+	}
+
+	online, err := adapter.PresenceStore.OnlineUsers(visibleEmails)
+	if err != nil {
+		// Fall back to this instance's sockets while Redis is unreachable.
+		online = make(map[string]bool, len(visibleEmails))
+		adapter.mux.RLock()
+		for _, userEmail := range visibleEmails {
+			online[userEmail] = adapter.onlineUsers[userEmail] > 0
+		}
+		adapter.mux.RUnlock()
+	}
+
+	idle, err := adapter.PresenceStore.IdleUsers(visibleEmails)
+	if err != nil {
+		logger.Error("failed to get idle users", "error", err)
+	}
+
+	presences := make(map[string]*Presence, len(users))
+	for _, user := range users {
+		if isOnline, visible := online[user.UserEmail]; visible {
+			presences[user.UserEmail] = adapter.buildPresence(user, isOnline, isOnline && idle[user.UserEmail])
+		} else {
+			presences[user.UserEmail] = &Presence{UserEmail: user.UserEmail}
 		}
 	}
-	return false
+	return presences
+}
+
+// endregion
+
+// region "buildPresence" assembles the full presence of a user, resetting a custom status that has expired
+func (adapter *socketAdapter) buildPresence(user *models.User, online, idle bool) *Presence {
+	presence := &Presence{
+		UserEmail:       user.UserEmail,
+		Online:          online,
+		Status:          user.Status,
+		StatusText:      user.StatusText,
+		StatusEmoji:     user.StatusEmoji,
+		StatusExpiresAt: user.StatusExpiresAt,
+	}
+
+	if user.StatusExpiresAt != nil && !user.StatusExpiresAt.After(time.Now()) {
+		presence.Status = types.Available
+		presence.StatusText, presence.StatusEmoji, presence.StatusExpiresAt = nil, nil, nil
+	}
+
+	if online {
+		presence.Idle = idle
+	} else {
+		presence.LastSeenAt = user.LastSeenAt
+	}
+	return presence
+}
+
+// endregion
+
+// region "getOnlineFriends" returns the emails of the user's friends that are online and share their presence
+func (adapter *socketAdapter) getOnlineFriends(ctx context.Context, userEmail string) ([]string, error) {
+	friends, err := adapter.FriendService.GetFriends(ctx, userEmail, false)
+	if err != nil {
+		return nil, err
+	}
+
+	onlineFriends := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		// Friends see "friends" presence, so only users hiding it from everybody are skipped.
+		if friend.User.PresenceVisibility == types.VisibleToNobody {
+			continue
+		}
+		if adapter.IsUserOnline(friend.UserMail) {
+			onlineFriends = append(onlineFriends, friend.UserMail)
+		}
+	}
+	return onlineFriends, nil
+}
+
+// endregion
+
+// region "emitPresence" notifies the users allowed to see it that the user came online or went offline
+func (adapter *socketAdapter) emitPresence(ctx context.Context, userEmail string, online bool) {
+	event := "user_offline"
+	if online {
+		event = "user_online"
+	}
+	adapter.emitPresenceEvent(ctx, event, userEmail, online)
+}
+
+// endregion
+
+// region "emitPresenceEvent" sends the user's current presence under the given event to the users allowed to see it
+func (adapter *socketAdapter) emitPresenceEvent(ctx context.Context, event, userEmail string, online bool) {
+	user, err := adapter.UserService.GetByEmail(ctx, userEmail)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to load presence settings", "user_email", userEmail, "error", err)
+		return
+	}
+
+	emitData := adapter.buildPresence(user, online, online && adapter.isIdle(ctx, userEmail))
+
+	switch user.PresenceVisibility {
+	case types.VisibleToNobody:
+		return
+	case types.VisibleToFriends:
+		err = adapter.emitToFriends(ctx, event, userEmail, emitData)
+	default:
+		err = adapter.EmitToFriendsAndSentRequests(ctx, event, userEmail, emitData)
+	}
+
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to emit presence", "user_email", userEmail, "error", err)
+	}
+}
+
+// endregion
+
+// region "emitToFriends" sends an event to the notification rooms of the specified user's friends only.
+func (adapter *socketAdapter) emitToFriends(ctx context.Context, event, userEmail string, emitData interface{}) error {
+	friends, err := adapter.FriendService.GetFriends(ctx, userEmail, false)
+	if err != nil {
+		return err
+	}
+
+	for _, friend := range friends {
+		adapter.Gateway.EmitToNotificationRoom(ctx, event, friend.UserMail, emitData)
+	}
+	return nil
 }
 
 // endregion
@@ -1,28 +1,29 @@
 package adapter
 
 import (
-	"fmt"
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"time"
 )
 
-// region "handleDisconnect" removes a user's email from the online users list when they disconnect.
-func (adapter *socketAdapter) handleDisconnect(email string) {
-	// Iterate through the list of online user emails.
-	for i, existingEmail := range adapter.onlineUserEmails {
-		// Check if the current email matches the disconnected user's email.
-		if existingEmail == email {
-			// Remove the email from the online users list.
-			adapter.onlineUserEmails = append(adapter.onlineUserEmails[:i], adapter.onlineUserEmails[i+1:]...)
-			fmt.Println(email, " is offline") // Log the user's disconnection.
+// region "handleDisconnect" marks a user offline and records their last-seen time once their last socket disconnects.
+func (adapter *socketAdapter) handleDisconnect(ctx context.Context, email, socketId string) {
+	logger := logging.FromContext(ctx)
+	logger.Debug("socket disconnected")
 
-			// Emit the updated online users list to notify all clients.
-			adapter.Gateway.Emit("onlineUsers", adapter.onlineUserEmails)
+	// Other tabs or devices of the user are still connected, possibly to another instance.
+	if !adapter.removeConnection(logger, email, socketId) {
+		return
+	}
+
+	logger.Info("user offline")
 
-			break // Exit the loop after removing the email.
-		}
+	if err := adapter.UserService.UpdateLastSeenByMail(ctx, time.Now().UTC(), email); err != nil {
+		logger.Error("failed to update last seen", "error", err)
 	}
 
-	// Emit the updated online users list again (redundant here, can be removed).
-	adapter.Gateway.Emit("onlineUsers", adapter.onlineUserEmails)
+	// Let only the users allowed to see it know they went offline.
+	adapter.emitPresence(ctx, email, false)
 }
 
 // endregion
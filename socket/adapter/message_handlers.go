@@ -1,18 +1,25 @@
 package adapter
 
 import (
+	"context"
 	"errors"
 	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
 	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/service"
 	"github.com/kwa0x2/swiftchat-backend/types"
 	"github.com/kwa0x2/swiftchat-backend/utils"
+	"gorm.io/gorm"
+	"time"
 )
 
 // region "handleSendMessage" processes sending a message in a chat room.
-func (adapter *socketAdapter) handleSendMessage(connectedUserID, connectedUserMail string, args ...any) {
+func (adapter *socketAdapter) handleSendMessage(ctx context.Context, connectedUserID, connectedUserMail string, args ...any) {
 	data, callback := utils.ExtractArgs(args)
 	if data == nil || callback == nil {
-		utils.SendResponse(callback, "error", "Invalid arguments")
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
 		return
 	}
 
@@ -30,8 +37,23 @@ func (adapter *socketAdapter) handleSendMessage(connectedUserID, connectedUserMa
 		MessageType: types.MessageType(data["message_type"].(string)),
 	}
 
-	addedMessageId, sendErr := adapter.SendMessage(&messageObj, connectedUserMail, data["user_email"].(string))
+	// Retries carrying the same client message ID return the original message.
+	if clientMessageId, ok := data["client_message_id"].(string); ok {
+		messageObj.ClientMessageID = &clientMessageId
+	}
+
+	// Channels have no single recipient, so the receiver email is optional.
+	receiverMail, _ := data["user_email"].(string)
+
+	addedMessageId, sendErr := adapter.SendMessage(ctx, &messageObj, connectedUserMail, receiverMail)
 	if sendErr != nil {
+		// Tell throttled clients how long to wait before sending again.
+		var slowModeErr *service.SlowModeError
+		if errors.As(sendErr, &slowModeErr) {
+			utils.SendRetryResponse(callback, slowModeErr.Error(), int(slowModeErr.RetryAfter.Seconds()))
+			return
+		}
+
 		utils.LogError(callback, sendErr.Error())
 		return
 	}
@@ -41,23 +63,71 @@ func (adapter *socketAdapter) handleSendMessage(connectedUserID, connectedUserMa
 
 // endregion
 
-// region "SendMessage" handles the actual sending of a message and notification to the recipient.
-func (adapter *socketAdapter) SendMessage(messageObj *models.Message, senderMail, receiverMail string) (string, error) {
-	// Check if the sender has blocked the receiver.
-	isBlocked, err := adapter.FriendService.IsBlocked(senderMail, receiverMail)
+// region "SendMessage" handles the actual sending of a message and notification to the recipient, recording how long it took.
+func (adapter *socketAdapter) SendMessage(ctx context.Context, messageObj *models.Message, senderMail, receiverMail string) (string, error) {
+	start := time.Now()
+	messageId, err := adapter.sendMessage(ctx, messageObj, senderMail, receiverMail)
+	metrics.MessageSendDuration.WithLabelValues(metrics.Outcome(err)).Observe(time.Since(start).Seconds())
+	return messageId, err
+}
+
+// endregion
+
+// region "sendMessage" checks, stores and emits a message.
+func (adapter *socketAdapter) sendMessage(ctx context.Context, messageObj *models.Message, senderMail, receiverMail string) (string, error) {
+	if err := adapter.MessageService.ValidateClientMessageID(messageObj.ClientMessageID); err != nil {
+		return "", err
+	}
+
+	// Only current members of the room may post in it.
+	userRoom, err := adapter.UserRoomService.GetUserRoom(ctx, messageObj.SenderID, messageObj.RoomID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", service.ErrNotRoomMember
+		}
 		return "", err
 	}
-	if isBlocked {
-		return "", errors.New("friend is blocked") // Return error if blocked.
+
+	isChannel := userRoom.Room != nil && userRoom.Room.RoomType == types.Channel
+
+	if isChannel {
+		// Channel subscribers can only read; posting is reserved for owners and admins.
+		if userRoom.RoomRole != types.Owner && userRoom.RoomRole != types.Admin {
+			return "", service.ErrChannelPostForbidden
+		}
+	} else {
+		// Check if the sender has blocked the receiver.
+		isBlocked, blockErr := adapter.FriendService.IsBlocked(ctx, senderMail, receiverMail)
+		if blockErr != nil {
+			return "", blockErr
+		}
+		if isBlocked {
+			return "", service.ErrFriendBlocked // Return error if blocked.
+		}
+	}
+
+	// A retried send returns the original message without storing or emitting it again.
+	if messageObj.ClientMessageID != nil {
+		original, originalErr := adapter.MessageService.GetByClientMessageID(ctx, messageObj.SenderID, *messageObj.ClientMessageID)
+		if originalErr != nil {
+			return "", originalErr
+		}
+		if original != nil {
+			return original.MessageID.String(), nil
+		}
 	}
 
-	// Insert the message and update the room.
-	addedMessageData, messageErr := adapter.MessageService.InsertAndUpdateRoom(messageObj)
+	// Insert the message and update the room, enforcing the room's slow mode.
+	addedMessageData, created, messageErr := adapter.MessageService.InsertAndUpdateRoom(ctx, messageObj)
 	if messageErr != nil {
 		return "", messageErr
 	}
 
+	// A concurrent retry stored the message first and already notified everyone.
+	if !created {
+		return addedMessageData.MessageID.String(), nil
+	}
+
 	// Prepare notification data to send to the recipient.
 	notifyData := map[string]interface{}{
 		"room_id":      addedMessageData.RoomID,
@@ -68,19 +138,42 @@ func (adapter *socketAdapter) SendMessage(messageObj *models.Message, senderMail
 	}
 
 	// Emit new message event to the chat room.
-	adapter.Gateway.EmitToRoomId("new_message", messageObj.RoomID.String(), addedMessageData)
-	// Emit notification of the new message to the recipient.
-	adapter.Gateway.EmitToNotificationRoom("new_message", receiverMail, notifyData)
+	adapter.Gateway.EmitToRoomId(ctx, "new_message", messageObj.RoomID.String(), addedMessageData)
+
+	// Channels notify all subscribers with one broadcast instead of one emit per member.
+	if isChannel {
+		adapter.Gateway.EmitToChannel("new_message", messageObj.RoomID.String(), notifyData)
+		return addedMessageData.MessageID.String(), nil
+	}
+
+	// Emit notification of the new message to the recipient, unless they muted the room and were not mentioned.
+	if !adapter.isNotificationMuted(ctx, messageObj.RoomID, receiverMail, addedMessageData.Message) {
+		adapter.Gateway.EmitToNotificationRoom(ctx, "new_message", receiverMail, notifyData)
+	}
 	return addedMessageData.MessageID.String(), nil
 }
 
 // endregion
 
+// region "isNotificationMuted" reports whether the recipient muted the room and the message does not mention them.
+func (adapter *socketAdapter) isNotificationMuted(ctx context.Context, roomId uuid.UUID, receiverMail, message string) bool {
+	mutedMember, err := adapter.UserRoomService.GetMutedMember(ctx, roomId, receiverMail)
+	if err != nil {
+		// Not muted, or the lookup failed: prefer an extra notification over a missed one.
+		return false
+	}
+
+	return !utils.IsMentioned(message, mutedMember.User.UserName)
+}
+
+// endregion
+
 // region "handleDeleteMessage" processes message deletion requests.
-func (adapter *socketAdapter) handleDeleteMessage(args ...any) {
+func (adapter *socketAdapter) handleDeleteMessage(ctx context.Context, connectedUserID, connectedUserMail string, args ...any) {
 	data, callback := utils.ExtractArgs(args)
 	if data == nil || callback == nil {
-		utils.SendResponse(callback, "error", "Invalid arguments")
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
 		return
 	}
 
@@ -90,7 +183,23 @@ func (adapter *socketAdapter) handleDeleteMessage(args ...any) {
 		return
 	}
 
-	deleteErr := adapter.DeleteMessage(data["user_email"].(string), data["room_id"].(string), messageID)
+	// Default to deleting for everyone to stay compatible with older clients.
+	deleteType := types.DeleteForEveryone
+	if value, ok := data["delete_type"].(string); ok && value != "" {
+		deleteType = types.DeleteType(value)
+	}
+
+	var deleteErr error
+	switch deleteType {
+	case types.DeleteForMe:
+		deleteErr = adapter.DeleteMessageForMe(ctx, connectedUserID, connectedUserMail, messageID)
+	case types.DeleteForEveryone:
+		deleteErr = adapter.DeleteMessage(ctx, connectedUserID, messageID)
+	default:
+		utils.LogError(callback, "invalid delete_type")
+		return
+	}
+
 	if deleteErr != nil {
 		utils.LogError(callback, deleteErr.Error())
 		return
@@ -101,32 +210,54 @@ func (adapter *socketAdapter) handleDeleteMessage(args ...any) {
 
 // endregion
 
-// region "DeleteMessage" handles the deletion of a message from the database and notifies clients.
-func (adapter *socketAdapter) DeleteMessage(connectedUserMail, roomId string, messageId uuid.UUID) error {
-	// Delete the message by its ID.
-	if err := adapter.MessageService.DeleteById(messageId); err != nil {
+// region "DeleteMessage" deletes a message for everyone and notifies clients.
+func (adapter *socketAdapter) DeleteMessage(ctx context.Context, connectedUserID string, messageId uuid.UUID) error {
+	// Delete the message by its ID, provided the user sent it recently enough.
+	message, err := adapter.MessageService.DeleteForEveryone(ctx, connectedUserID, messageId)
+	if err != nil {
+		return err
+	}
+
+	notifyData := map[string]interface{}{
+		"room_id":    message.RoomID,
+		"message_id": messageId,
+	}
+
+	// Emit message deletion event to the chat room the message was sent to.
+	adapter.Gateway.EmitToRoomId(ctx, "delete_message", message.RoomID.String(), messageId)
+	// Emit notification of the deleted message to the room's members.
+	adapter.notifyRoomMembers(ctx, connectedUserID, message.RoomID, "delete_message", notifyData)
+	return nil
+}
+
+// endregion
+
+// region "DeleteMessageForMe" hides a message for the current user and syncs their other sessions.
+func (adapter *socketAdapter) DeleteMessageForMe(ctx context.Context, connectedUserID, connectedUserMail string, messageId uuid.UUID) error {
+	// Hide the message, provided the user is a member of its room.
+	message, err := adapter.MessageService.DeleteForMe(ctx, connectedUserID, messageId)
+	if err != nil {
 		return err
 	}
 
 	notifyData := map[string]interface{}{
-		"room_id":    roomId,
+		"room_id":    message.RoomID,
 		"message_id": messageId,
 	}
 
-	// Emit message deletion event to the chat room.
-	adapter.Gateway.EmitToRoomId("delete_message", roomId, messageId)
-	// Emit notification of the deleted message to the user.
-	adapter.Gateway.EmitToNotificationRoom("delete_message", connectedUserMail, notifyData)
+	// Only the user's own sessions need to drop the message.
+	adapter.Gateway.EmitToNotificationRoom(ctx, "hide_message", connectedUserMail, notifyData)
 	return nil
 }
 
 // endregion
 
 // region "handleEditMessage" processes message edit requests.
-func (adapter *socketAdapter) handleEditMessage(args ...any) {
+func (adapter *socketAdapter) handleEditMessage(ctx context.Context, connectedUserID string, args ...any) {
 	data, callback := utils.ExtractArgs(args)
 	if data == nil || callback == nil {
-		utils.SendResponse(callback, "error", "Invalid arguments")
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
 		return
 	}
 
@@ -136,7 +267,7 @@ func (adapter *socketAdapter) handleEditMessage(args ...any) {
 		return
 	}
 
-	editErr := adapter.EditMessage(data["user_email"].(string), data["room_id"].(string), data["edited_message"].(string), messageID)
+	editErr := adapter.EditMessage(ctx, connectedUserID, data["edited_message"].(string), messageID)
 	if editErr != nil {
 		utils.LogError(callback, editErr.Error())
 		return
@@ -146,33 +277,66 @@ func (adapter *socketAdapter) handleEditMessage(args ...any) {
 
 // endregion
 
-// region "EditMessage" updates a message in the database and notifies clients of the change.
-func (adapter *socketAdapter) EditMessage(connectedUserMail, roomId, editedMessage string, messageId uuid.UUID) error {
-	// Update the message by its ID.
-	if err := adapter.MessageService.UpdateMessageById(messageId, editedMessage); err != nil {
+// region "EditMessage" updates a message sent by the user and notifies the members of its room of the change.
+func (adapter *socketAdapter) EditMessage(ctx context.Context, connectedUserID, editedMessage string, messageId uuid.UUID) error {
+	// Update the message by its ID, provided the user sent it.
+	message, err := adapter.MessageService.EditMessage(ctx, connectedUserID, messageId, editedMessage)
+	if err != nil {
 		return err
 	}
 
 	// Prepare notification data for the edited message.
 	notifyData := map[string]interface{}{
+		"room_id":        message.RoomID,
 		"message_id":     messageId,
 		"edited_message": editedMessage,
 	}
 
-	// Emit message edit event to the chat room.
-	adapter.Gateway.EmitToRoomId("edit_message", roomId, notifyData)
-	// Emit notification of the edited message to the user.
-	adapter.Gateway.EmitToNotificationRoom("edit_message", connectedUserMail, notifyData)
+	// Emit message edit event to the chat room the message was sent to.
+	adapter.Gateway.EmitToRoomId(ctx, "edit_message", message.RoomID.String(), notifyData)
+	// Emit notification of the edited message to the room's members.
+	adapter.notifyRoomMembers(ctx, connectedUserID, message.RoomID, "edit_message", notifyData)
 	return nil
 }
 
 // endregion
 
+// region "notifyRoomMembers" sends a notification about one of a room's messages to the room's members, or to its subscribers for channels.
+// The change itself already happened, so failures are only logged.
+func (adapter *socketAdapter) notifyRoomMembers(ctx context.Context, connectedUserID string, roomId uuid.UUID, notifyAction string, notifyData any) {
+	logger := logging.FromContext(ctx)
+
+	// The acting user's membership tells whether the room is a channel.
+	userRoom, err := adapter.UserRoomService.GetUserRoom(ctx, connectedUserID, roomId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to load room for notification", "room_id", roomId, "action", notifyAction, "error", err)
+		return
+	}
+
+	if userRoom != nil && userRoom.Room != nil && userRoom.Room.RoomType == types.Channel {
+		adapter.Gateway.EmitToChannel(notifyAction, roomId.String(), notifyData)
+		return
+	}
+
+	memberEmails, err := adapter.UserRoomService.GetMemberEmails(ctx, roomId)
+	if err != nil {
+		logger.Error("failed to load room members for notification", "room_id", roomId, "action", notifyAction, "error", err)
+		return
+	}
+
+	for _, memberEmail := range memberEmails {
+		adapter.Gateway.EmitToNotificationRoom(ctx, notifyAction, memberEmail, notifyData)
+	}
+}
+
+// endregion
+
 // region "handleUpdateMessageStarred" processes requests to update message type.
-func (adapter *socketAdapter) handleUpdateMessageStarred(args ...any) {
+func (adapter *socketAdapter) handleUpdateMessageStarred(ctx context.Context, args ...any) {
 	data, callback := utils.ExtractArgs(args)
 	if data == nil || callback == nil {
-		utils.SendResponse(callback, "error", "Invalid arguments")
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
 		return
 	}
 
@@ -182,7 +346,7 @@ func (adapter *socketAdapter) handleUpdateMessageStarred(args ...any) {
 		return
 	}
 
-	starErr := adapter.UpdateMessageStarred(data["room_id"].(string), messageID, data["message_starred"].(bool))
+	starErr := adapter.UpdateMessageStarred(ctx, data["room_id"].(string), messageID, data["message_starred"].(bool))
 	if starErr != nil {
 		utils.LogError(callback, starErr.Error())
 		return
@@ -193,9 +357,9 @@ func (adapter *socketAdapter) handleUpdateMessageStarred(args ...any) {
 // endregion
 
 // region "updateMessageStarred" updates the message type and notifies clients.
-func (adapter *socketAdapter) UpdateMessageStarred(roomId string, messageId uuid.UUID, messageStarred bool) error {
+func (adapter *socketAdapter) UpdateMessageStarred(ctx context.Context, roomId string, messageId uuid.UUID, messageStarred bool) error {
 	// Update the message type in the database.
-	if err := adapter.MessageService.UpdateMessageStarredById(messageId, messageStarred); err != nil {
+	if err := adapter.MessageService.UpdateMessageStarredById(ctx, messageId, messageStarred); err != nil {
 		return err
 	}
 
@@ -204,23 +368,24 @@ func (adapter *socketAdapter) UpdateMessageStarred(roomId string, messageId uuid
 		"message_starred": messageStarred,
 	}
 
-	adapter.Gateway.EmitToRoomId("updated_message_starred", roomId, notifyData)
+	adapter.Gateway.EmitToRoomId(ctx, "updated_message_starred", roomId, notifyData)
 	return nil
 }
 
 // endregion
 
 // region "handleReadMessage" processes marking messages as read.
-func (adapter *socketAdapter) handleReadMessage(connectedUserID string, args ...any) {
+func (adapter *socketAdapter) handleReadMessage(ctx context.Context, connectedUserID string, args ...any) {
 	data, callback := utils.ExtractArgs(args)
 	if data == nil || callback == nil {
-		utils.SendResponse(callback, "error", "Invalid arguments")
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
 		return
 	}
 	messageId, okMessage := data["message_id"].(string)
 
 	if !okMessage || messageId == "" { // If no message ID is provided.
-		err := adapter.ReadMessage(connectedUserID, data["room_id"].(string), nil) // Read the room without specific message.
+		err := adapter.ReadMessage(ctx, connectedUserID, data["room_id"].(string), nil) // Read the room without specific message.
 		if err != nil {
 			utils.LogError(callback, err.Error())
 			return
@@ -230,7 +395,7 @@ func (adapter *socketAdapter) handleReadMessage(connectedUserID string, args ...
 	}
 
 	// Attempt to read the specific message.
-	err := adapter.ReadMessage(connectedUserID, data["room_id"].(string), &messageId)
+	err := adapter.ReadMessage(ctx, connectedUserID, data["room_id"].(string), &messageId)
 	if err != nil {
 		utils.LogError(callback, err.Error())
 		return
@@ -241,8 +406,8 @@ func (adapter *socketAdapter) handleReadMessage(connectedUserID string, args ...
 // endregion
 
 // region "ReadMessage" marks message(s) as read in the database and notifies clients.
-func (adapter *socketAdapter) ReadMessage(connectedUserID, roomId string, messageId *string) error {
-	if err := adapter.MessageService.ReadMessageByRoomId(connectedUserID, roomId, messageId); err != nil {
+func (adapter *socketAdapter) ReadMessage(ctx context.Context, connectedUserID, roomId string, messageId *string) error {
+	if err := adapter.MessageService.ReadMessageByRoomId(ctx, connectedUserID, roomId, messageId); err != nil {
 		return err
 	}
 
@@ -250,7 +415,7 @@ func (adapter *socketAdapter) ReadMessage(connectedUserID, roomId string, messag
 		"room_id": roomId,
 	}
 
-	adapter.Gateway.EmitToRoomId("read_message", roomId, notifyData)
+	adapter.Gateway.EmitToRoomId(ctx, "read_message", roomId, notifyData)
 	return nil
 }
 
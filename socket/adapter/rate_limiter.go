@@ -0,0 +1,223 @@
+package adapter
+
+import (
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/config"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/internal/metrics"
+	"github.com/kwa0x2/swiftchat-backend/internal/tracing"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"github.com/zishang520/socket.io/socket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	rateLimitViolationWindow = time.Minute // Window in which a socket's throttled events are counted.
+	rateLimitSweepInterval   = time.Minute // How often buckets that refilled completely are dropped.
+)
+
+// RateLimitError is returned when a user exceeds the rate limit of an event.
+type RateLimitError struct {
+	RetryAfter time.Duration // How long the user has to wait before the event is allowed again.
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limit exceeded"
+}
+
+// region tokenBucket tracks the tokens left for one user and event.
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// endregion
+
+// rateLimiter keeps a token bucket per user and socket event.
+// Buckets outlive the user's sockets, so reconnecting does not refill them; they are dropped once idle long enough to be full again.
+type rateLimiter struct {
+	limits    map[string]config.RateLimit
+	mux       sync.Mutex
+	buckets   map[string]map[string]*tokenBucket // Buckets per user email and event, guarded by mux
+	lastSweep time.Time                          // When idle buckets were last dropped, guarded by mux
+}
+
+func newRateLimiter(limits map[string]config.RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limits:    limits,
+		buckets:   make(map[string]map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// region "allow" takes a token for the user's event, returning false and the wait until the next token when none is left
+func (l *rateLimiter) allow(userEmail, event string) (bool, time.Duration) {
+	limit, ok := l.limits[event]
+	if !ok {
+		return true, 0 // Events without a configured limit are not throttled.
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	if l.buckets[userEmail] == nil {
+		l.buckets[userEmail] = make(map[string]*tokenBucket)
+	}
+
+	bucket, ok := l.buckets[userEmail][event]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.Burst), lastRefill: now}
+		l.buckets[userEmail][event] = bucket
+	}
+
+	// Refill for the time elapsed since the last event, capped at the burst size.
+	bucket.tokens = math.Min(float64(limit.Burst), bucket.tokens+now.Sub(bucket.lastRefill).Seconds()*limit.Rate)
+	bucket.lastRefill = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limit.Rate * float64(time.Second))
+	}
+
+	bucket.tokens--
+	return true, 0
+}
+
+// endregion
+
+// region "CheckRateLimit" takes a token for the user's event outside of a socket, so REST endpoints share the socket event's limit
+func (adapter *socketAdapter) CheckRateLimit(userEmail, event string) error {
+	if allowed, retryAfter := adapter.RateLimiter.allow(userEmail, event); !allowed {
+		return &RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// endregion
+
+// region "sweep" drops the buckets that have been idle long enough to refill completely, which a new bucket would match; mux must be held
+func (l *rateLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+
+	for userEmail, events := range l.buckets {
+		for event, bucket := range events {
+			limit := l.limits[event]
+			refillTime := time.Duration((float64(limit.Burst) - bucket.tokens) / limit.Rate * float64(time.Second))
+			if now.Sub(bucket.lastRefill) >= refillTime {
+				delete(events, event)
+			}
+		}
+		if len(events) == 0 {
+			delete(l.buckets, userEmail)
+		}
+	}
+}
+
+// endregion
+
+// region socketViolations counts the throttled events of one socket within the current window.
+type socketViolations struct {
+	mux         sync.Mutex
+	count       int
+	windowStart time.Time
+}
+
+// endregion
+
+// region "add" records a throttled event and returns the number recorded in the current window
+func (v *socketViolations) add() int {
+	v.mux.Lock()
+	defer v.mux.Unlock()
+
+	now := time.Now()
+	if now.Sub(v.windowStart) > rateLimitViolationWindow {
+		v.count, v.windowStart = 0, now
+	}
+
+	v.count++
+	return v.count
+}
+
+// endregion
+
+// region "limited" wraps a socket event handler so it only runs while the user is within the event's rate limit.
+// Throttled events get an error ack with the retry delay, and sockets that keep flooding are disconnected.
+// Each event is handled in its own span, linked to the trace of the request that opened the socket.
+func (adapter *socketAdapter) limited(socketio *socket.Socket, socketCtx context.Context, connectedUserID, connectedUserMail, event string, violations *socketViolations, handler func(ctx context.Context, args ...any)) func(args ...any) {
+	return func(args ...any) {
+		metrics.SocketEvents.WithLabelValues(event).Inc()
+
+		attributes := append(socketAttributes(string(socketio.Id()), connectedUserID), attribute.String("socket.event", event))
+		ctx, span := tracing.StartLinked(socketCtx, "socket "+event, attributes...)
+		defer span.End()
+		logger := logging.FromContext(ctx)
+
+		// A failing handler must not take the whole process down with it.
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				metrics.SocketEventErrors.WithLabelValues(event).Inc()
+				span.SetStatus(codes.Error, "handler panicked")
+				logger.Error("socket event handler panicked", "event", event, "panic", recovered, "stack", string(debug.Stack()))
+			}
+		}()
+
+		allowed, retryAfter := adapter.RateLimiter.allow(connectedUserMail, event)
+		if allowed {
+			handler(ctx, observeErrorResponses(ctx, event, args)...)
+			return
+		}
+
+		metrics.SocketEventErrors.WithLabelValues(event).Inc()
+		span.SetStatus(codes.Error, "rate limit exceeded")
+
+		if count := violations.add(); count > adapter.MaxRateLimitViolations {
+			logger.Warn("disconnecting throttled socket", "event", event, "violations", count)
+			socketio.Disconnect(true)
+			return
+		}
+
+		if len(args) == 0 {
+			return
+		}
+		if callback, ok := args[len(args)-1].(func([]interface{}, error)); ok {
+			utils.SendRetryResponse(callback, "rate limit exceeded", int(math.Ceil(retryAfter.Seconds())))
+		}
+	}
+}
+
+// endregion
+
+// region "observeErrorResponses" wraps the acknowledgement callback of an event, if any, to count and log error responses
+func observeErrorResponses(ctx context.Context, event string, args []any) []any {
+	if len(args) == 0 {
+		return args
+	}
+	callback, ok := args[len(args)-1].(func([]interface{}, error))
+	if !ok {
+		return args
+	}
+
+	wrapped := append([]any{}, args[:len(args)-1]...)
+	return append(wrapped, func(response []interface{}, err error) {
+		if len(response) > 0 {
+			if result, ok := response[0].(utils.Response); ok && result.Status == "error" {
+				metrics.SocketEventErrors.WithLabelValues(event).Inc()
+				trace.SpanFromContext(ctx).SetStatus(codes.Error, result.Message)
+				logging.FromContext(ctx).Warn("socket event failed", "event", event, "error", result.Message)
+			}
+		}
+		callback(response, err)
+	})
+}
+
+// endregion
@@ -1,19 +1,133 @@
 package adapter
 
 import (
-	"github.com/zishang520/engine.io/utils"
+	"context"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/models"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
+	"github.com/kwa0x2/swiftchat-backend/socket/gateway"
+	"github.com/kwa0x2/swiftchat-backend/utils"
 	"github.com/zishang520/socket.io/socket"
 )
 
+const (
+	maxSyncRooms           = 100            // Rooms a client can sync with a single request.
+	legacyNotificationRoom = "notification" // Room older clients join to receive their notifications.
+)
+
 // region "handleJoinRoom" handles the event when a socket joins a specific room.
-func (adapter *socketAdapter) handleJoinRoom(socketio *socket.Socket, roomData ...any) {
+// Sockets may only join chat rooms they are members of; every other room is joined by the server.
+func (adapter *socketAdapter) handleJoinRoom(ctx context.Context, socketio *socket.Socket, connectedUserID, connectedUserMail string, roomData ...any) {
+	logger := logging.FromContext(ctx)
+
 	// Attempt to retrieve the room ID from the provided roomData.
-	roomId, ok := roomData[0].(string)
+	var roomId string
+	ok := len(roomData) > 0
+	if ok {
+		roomId, ok = roomData[0].(string)
+	}
 	if !ok {
-		utils.Log().Error(`socket message type error socketid: %s `, socketio.Id())
+		logger.Warn("joinRoom expects a room ID")
+		return
+	}
+
+	// The user's notification room was already joined on connect; older clients still ask for it.
+	if roomId == legacyNotificationRoom || roomId == connectedUserMail {
+		adapter.Gateway.JoinRoom(socketio, gateway.NotificationRoom(connectedUserMail))
+		return
+	}
+
+	// Chat rooms are identified by UUIDs and may only be joined by their members.
+	parsedRoomId, err := uuid.Parse(roomId)
+	if err != nil {
+		logger.Warn("refused to join a room that is not a chat room", "room_id", roomId)
+		return
+	}
+	if _, memberErr := adapter.UserRoomService.GetUserRoom(ctx, connectedUserID, parsedRoomId); memberErr != nil {
+		logger.Warn("refused to join a room the user is not a member of", "room_id", roomId)
 		return
 	}
+
 	adapter.Gateway.JoinRoom(socketio, roomId)
 }
 
 // endregion
+
+// region SyncedRoom represents the events of a room a client missed while disconnected.
+type SyncedRoom struct {
+	Events   []*cluster.RoomEvent `json:"events"`
+	Complete bool                 `json:"complete"` // False when older events were dropped and the client must reload the room
+}
+
+// endregion
+
+// region "handleSync" replays the room events a reconnecting client missed, given the last sequence number it saw per room.
+func (adapter *socketAdapter) handleSync(ctx context.Context, connectedUserID string, args ...any) {
+	data, callback := utils.ExtractArgs(args)
+	if data == nil || callback == nil {
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
+		return
+	}
+
+	rooms, ok := data["rooms"].(map[string]interface{})
+	if !ok {
+		utils.LogError(callback, "rooms must map room IDs to their last seen sequence number")
+		return
+	}
+
+	if len(rooms) > maxSyncRooms {
+		utils.LogError(callback, "too many rooms to sync at once")
+		return
+	}
+
+	synced := make(map[string]*SyncedRoom, len(rooms))
+	for roomId, lastSeq := range rooms {
+		afterSeq, ok := lastSeq.(float64)
+		if !ok || afterSeq < 0 {
+			continue
+		}
+
+		// Only members may read a room's events.
+		parsedRoomId, err := uuid.Parse(roomId)
+		if err != nil {
+			continue
+		}
+		userRoom, memberErr := adapter.UserRoomService.GetUserRoom(ctx, connectedUserID, parsedRoomId)
+		if memberErr != nil {
+			continue
+		}
+
+		events, complete, logErr := adapter.EventLog.Since(roomId, int64(afterSeq))
+		if logErr != nil {
+			utils.LogError(callback, "Error reading missed events")
+			return
+		}
+		synced[roomId] = &SyncedRoom{Events: visibleEvents(events, userRoom), Complete: complete}
+	}
+
+	utils.SendDataResponse(callback, "Rooms synced successfully", synced)
+}
+
+// endregion
+
+// region "visibleEvents" drops the events emitted before the member joined the room or last cleared its history.
+func visibleEvents(events []*cluster.RoomEvent, userRoom *models.UserRoom) []*cluster.RoomEvent {
+	visibleFrom := userRoom.CreatedAt
+	if userRoom.ClearedAt != nil && userRoom.ClearedAt.After(visibleFrom) {
+		visibleFrom = *userRoom.ClearedAt
+	}
+
+	visible := make([]*cluster.RoomEvent, 0, len(events))
+	for _, event := range events {
+		if event.EmittedAt.Before(visibleFrom) {
+			continue
+		}
+		visible = append(visible, event)
+	}
+
+	return visible
+}
+
+// endregion
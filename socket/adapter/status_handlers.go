@@ -0,0 +1,169 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/service"
+	"github.com/kwa0x2/swiftchat-backend/types"
+	"github.com/kwa0x2/swiftchat-backend/utils"
+	"time"
+)
+
+const (
+	IdleTimeout       = 5 * time.Minute  // Heartbeat gap after which an online user is shown as idle.
+	idleCheckInterval = 30 * time.Second // How often heartbeat gaps and status expiries are checked.
+)
+
+// region "handleSetStatus" processes requests to change the user's availability and custom status.
+func (adapter *socketAdapter) handleSetStatus(ctx context.Context, connectedUserMail string, args ...any) {
+	data, callback := utils.ExtractArgs(args)
+	if data == nil || callback == nil {
+		// Without an acknowledgement callback there is nothing to reply to.
+		logging.FromContext(ctx).Warn("socket event has invalid arguments")
+		return
+	}
+
+	status := types.Available
+	if value, ok := data["status"].(string); ok && value != "" {
+		status = types.UserStatus(value)
+	}
+
+	// Empty strings clear the custom text and emoji.
+	var statusText, statusEmoji *string
+	if value, ok := data["status_text"].(string); ok && value != "" {
+		statusText = &value
+	}
+	if value, ok := data["status_emoji"].(string); ok && value != "" {
+		statusEmoji = &value
+	}
+
+	// The expiry is given in seconds from now so client clocks do not matter.
+	var expiresAt *time.Time
+	if seconds, ok := data["expires_in"].(float64); ok && seconds > 0 {
+		expiry := time.Now().UTC().Add(time.Duration(seconds) * time.Second)
+		expiresAt = &expiry
+	}
+
+	if err := adapter.UserService.UpdateStatusByMail(ctx, status, statusText, statusEmoji, expiresAt, connectedUserMail); err != nil {
+		if errors.Is(err, service.ErrInvalidStatus) || errors.Is(err, service.ErrStatusTextTooLong) ||
+			errors.Is(err, service.ErrStatusEmojiTooLong) || errors.Is(err, service.ErrStatusExpiryInPast) {
+			utils.LogError(callback, err.Error())
+			return
+		}
+		utils.LogError(callback, "Error updating status")
+		return
+	}
+
+	adapter.emitPresenceEvent(ctx, "status_updated", connectedUserMail, true)
+	utils.LogSuccess(callback, "Status updated successfully")
+}
+
+// endregion
+
+// region "handleHeartbeat" records client activity and clears the user's idle state.
+func (adapter *socketAdapter) handleHeartbeat(ctx context.Context, connectedUserMail string) {
+	adapter.mux.RLock()
+	connected := adapter.onlineUsers[connectedUserMail] > 0
+	adapter.mux.RUnlock()
+	if !connected {
+		return // Late heartbeat from a socket that already disconnected.
+	}
+
+	wasIdle, err := adapter.PresenceStore.Heartbeat(connectedUserMail)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to record heartbeat", "error", err)
+		return
+	}
+
+	if wasIdle {
+		adapter.emitPresenceEvent(ctx, "status_updated", connectedUserMail, true)
+	}
+}
+
+// endregion
+
+// region "watchIdleUsers" periodically announces users whose sockets expired as offline, marks online users without recent heartbeats as idle,
+// resets expired statuses and tells the users' friends. It returns once the context is cancelled.
+func (adapter *socketAdapter) watchIdleUsers(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		adapter.reapOfflineUsers(ctx)
+		adapter.markIdleUsers(ctx)
+		adapter.resetExpiredStatuses(ctx)
+	}
+}
+
+// endregion
+
+// region "reapOfflineUsers" announces the users whose sockets expired without disconnecting, such as those of a crashed instance, as offline.
+func (adapter *socketAdapter) reapOfflineUsers(ctx context.Context) {
+	logger := logging.FromContext(ctx)
+
+	emails, err := adapter.PresenceStore.ReapExpired()
+	if err != nil {
+		logger.Error("failed to reap expired presence", "error", err)
+		return
+	}
+
+	for _, email := range emails {
+		logger.Info("user offline", "user_email", email)
+
+		if updateErr := adapter.UserService.UpdateLastSeenByMail(ctx, time.Now().UTC(), email); updateErr != nil {
+			logger.Error("failed to update last seen", "user_email", email, "error", updateErr)
+		}
+		adapter.emitPresence(ctx, email, false)
+	}
+}
+
+// endregion
+
+// region "markIdleUsers" flags online users without a heartbeat for IdleTimeout as idle and tells the users' friends.
+func (adapter *socketAdapter) markIdleUsers(ctx context.Context) {
+	emails, err := adapter.PresenceStore.MarkIdle(time.Now().Add(-IdleTimeout))
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to mark idle users", "error", err)
+		return
+	}
+
+	for _, email := range emails {
+		adapter.emitPresenceEvent(ctx, "status_updated", email, true)
+	}
+}
+
+// endregion
+
+// region "resetExpiredStatuses" resets the statuses that expired and tells the users' friends.
+func (adapter *socketAdapter) resetExpiredStatuses(ctx context.Context) {
+	emails, err := adapter.UserService.ResetExpiredStatuses(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to reset expired statuses", "error", err)
+		return
+	}
+
+	for _, email := range emails {
+		adapter.emitPresenceEvent(ctx, "status_updated", email, adapter.IsUserOnline(email))
+	}
+}
+
+// endregion
+
+// region "isIdle" reports whether the online user stopped sending heartbeats.
+func (adapter *socketAdapter) isIdle(ctx context.Context, email string) bool {
+	idle, err := adapter.PresenceStore.IsIdle(email)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to get idle state", "user_email", email, "error", err)
+		return false
+	}
+	return idle
+}
+
+// endregion
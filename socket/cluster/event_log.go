@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"encoding/json"
+	"github.com/gomodule/redigo/redis"
+	"time"
+)
+
+const (
+	MaxRoomEvents      = 500            // Events kept per room for replay.
+	RoomEventRetention = 24 * time.Hour // Event logs of rooms without activity for this long are dropped.
+	roomSeqKeyPrefix   = "room_seq:"
+	roomEventKeyPrefix = "room_events:"
+)
+
+// appendEventScript assigns the next sequence number of a room and stores the event under it, trimming the log.
+var appendEventScript = redis.NewScript(2, `
+local seq = redis.call('INCR', KEYS[1])
+local entry = '{"seq":' .. seq .. ',' .. string.sub(ARGV[1], 2)
+redis.call('ZADD', KEYS[2], seq, entry)
+redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -(tonumber(ARGV[2]) + 1))
+redis.call('PEXPIRE', KEYS[2], ARGV[3])
+return seq
+`)
+
+// region RoomEvent is an entry of a room's event log.
+type RoomEvent struct {
+	Seq       int64           `json:"seq"`
+	Action    string          `json:"action"`
+	Data      json.RawMessage `json:"data"`
+	EmittedAt time.Time       `json:"emittedAt"`
+}
+
+// endregion
+
+type IEventLog interface {
+	Append(roomId, action string, data any) (int64, error)
+	Since(roomId string, afterSeq int64) ([]*RoomEvent, bool, error)
+}
+
+// eventLog numbers room events and keeps the most recent ones in Redis, so every instance shares the same sequence.
+type eventLog struct {
+	pool *redis.Pool
+}
+
+func NewEventLog(pool *redis.Pool) IEventLog {
+	return &eventLog{
+		pool: pool,
+	}
+}
+
+// region "Append" records an event of a room and returns its sequence number
+func (l *eventLog) Append(roomId, action string, data any) (int64, error) {
+	entry, err := json.Marshal(map[string]any{
+		"action":    action,
+		"data":      data,
+		"emittedAt": time.Now().UTC(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	return redis.Int64(appendEventScript.Do(conn, roomSeqKeyPrefix+roomId, roomEventKeyPrefix+roomId,
+		entry, MaxRoomEvents, RoomEventRetention.Milliseconds()))
+}
+
+// endregion
+
+// region "Since" returns the events of a room after the given sequence number.
+// The boolean is false when some of those events are no longer retained and the client has to reload the room instead.
+func (l *eventLog) Since(roomId string, afterSeq int64) ([]*RoomEvent, bool, error) {
+	conn := l.pool.Get()
+	defer conn.Close()
+
+	latestSeq, err := redis.Int64(conn.Do("GET", roomSeqKeyPrefix+roomId))
+	if err == redis.ErrNil {
+		return nil, afterSeq == 0, nil // Nothing was ever emitted in the room.
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if latestSeq <= afterSeq {
+		return nil, latestSeq == afterSeq, nil
+	}
+
+	entries, err := redis.ByteSlices(conn.Do("ZRANGEBYSCORE", roomEventKeyPrefix+roomId, afterSeq+1, "+inf"))
+	if err != nil {
+		return nil, false, err
+	}
+
+	events := make([]*RoomEvent, 0, len(entries))
+	for _, entry := range entries {
+		var event RoomEvent
+		if err := json.Unmarshal(entry, &event); err != nil {
+			return nil, false, err
+		}
+		events = append(events, &event)
+	}
+
+	complete := len(events) > 0 && events[0].Seq == afterSeq+1
+	return events, complete, nil
+}
+
+// endregion
@@ -0,0 +1,224 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gomodule/redigo/redis"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MaxUserNotifications          = 500            // Notifications kept per user for resuming streams.
+	NotificationRetention         = 24 * time.Hour // Notification logs of users without new notifications for this long are dropped.
+	notificationStreamKeyPrefix   = "notifications:"
+	initialNotificationID         = "0-0"
+	notificationStreamEntryAction = "action"
+	notificationStreamEntryData   = "data"
+)
+
+var ErrInvalidNotificationID = errors.New("invalid notification id")
+
+// appendNotificationScript adds a notification to a user's stream, trimming it and refreshing its expiry.
+var appendNotificationScript = redis.NewScript(1, `
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '*', 'action', ARGV[2], 'data', ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return id
+`)
+
+// region Notification is an entry of a user's notification stream.
+type Notification struct {
+	ID     string          `json:"id"`
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// endregion
+
+type INotificationStream interface {
+	Append(receiverMail, action string, data any) (string, error)
+	Start(receiverMail, lastEventId string) (string, bool, error)
+	Read(receiverMail, afterId string, block time.Duration) ([]*Notification, error)
+}
+
+// notificationStream keeps the notifications of every user in a Redis stream, so any instance can serve and resume them.
+type notificationStream struct {
+	pool *redis.Pool
+}
+
+func NewNotificationStream(pool *redis.Pool) INotificationStream {
+	return &notificationStream{
+		pool: pool,
+	}
+}
+
+// region "Append" records a notification of a user and returns its ID
+func (s *notificationStream) Append(receiverMail, action string, data any) (string, error) {
+	entry, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.String(appendNotificationScript.Do(conn, notificationStreamKeyPrefix+receiverMail,
+		MaxUserNotifications, action, entry, NotificationRetention.Milliseconds()))
+}
+
+// endregion
+
+// region "Start" returns the ID a new stream of a user has to read after.
+// Without a last event ID the stream starts at the newest notification. The boolean is false when notifications
+// after the last event ID are no longer retained and the client has to reload its state instead.
+func (s *notificationStream) Start(receiverMail, lastEventId string) (string, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	if lastEventId == "" {
+		newest, err := s.boundaryID(conn, "XREVRANGE", receiverMail, "+", "-")
+		if err != nil || newest == "" {
+			return initialNotificationID, true, err
+		}
+		return newest, true, nil
+	}
+
+	lastEventKey, ok := parseNotificationID(lastEventId)
+	if !ok {
+		return "", false, ErrInvalidNotificationID
+	}
+
+	oldest, err := s.boundaryID(conn, "XRANGE", receiverMail, "-", "+")
+	if err != nil {
+		return "", false, err
+	}
+	if oldest == "" {
+		return lastEventId, false, nil // Everything after the last event expired.
+	}
+
+	// The stream still holds the last event, or nothing older was trimmed away.
+	oldestKey, _ := parseNotificationID(oldest)
+	complete := compareNotificationIDs(oldestKey, lastEventKey) <= 0
+	return lastEventId, complete, nil
+}
+
+// endregion
+
+// region "Read" waits up to the block duration for notifications of a user after the given ID
+func (s *notificationStream) Read(receiverMail, afterId string, block time.Duration) ([]*Notification, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("XREAD", "COUNT", 100, "BLOCK", block.Milliseconds(),
+		"STREAMS", notificationStreamKeyPrefix+receiverMail, afterId))
+	if err == redis.ErrNil {
+		return nil, nil // Nothing arrived within the block duration.
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications []*Notification
+	for _, stream := range reply {
+		streamValues, err := redis.Values(stream, nil)
+		if err != nil || len(streamValues) != 2 {
+			return nil, errors.New("unexpected XREAD reply")
+		}
+
+		entries, err := redis.Values(streamValues[1], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			notification, err := parseNotificationEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications, nil
+}
+
+// endregion
+
+// region "boundaryID" returns the ID of the first entry of a user's stream in the given range direction, or "" if it is empty
+func (s *notificationStream) boundaryID(conn redis.Conn, command, receiverMail, start, end string) (string, error) {
+	entries, err := redis.Values(conn.Do(command, notificationStreamKeyPrefix+receiverMail, start, end, "COUNT", 1))
+	if err != nil || len(entries) == 0 {
+		return "", err
+	}
+
+	notification, err := parseNotificationEntry(entries[0])
+	if err != nil {
+		return "", err
+	}
+	return notification.ID, nil
+}
+
+// endregion
+
+// region "parseNotificationEntry" converts a raw stream entry into a notification
+func parseNotificationEntry(entry any) (*Notification, error) {
+	values, err := redis.Values(entry, nil)
+	if err != nil || len(values) != 2 {
+		return nil, errors.New("unexpected stream entry")
+	}
+
+	id, err := redis.String(values[0], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := redis.StringMap(values[1], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Notification{
+		ID:     id,
+		Action: fields[notificationStreamEntryAction],
+		Data:   json.RawMessage(fields[notificationStreamEntryData]),
+	}, nil
+}
+
+// endregion
+
+// region "parseNotificationID" splits a stream ID into its millisecond and sequence parts
+func parseNotificationID(id string) ([2]uint64, bool) {
+	millis, seq, found := strings.Cut(id, "-")
+	if !found {
+		return [2]uint64{}, false
+	}
+
+	millisPart, err := strconv.ParseUint(millis, 10, 64)
+	if err != nil {
+		return [2]uint64{}, false
+	}
+	seqPart, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return [2]uint64{}, false
+	}
+
+	return [2]uint64{millisPart, seqPart}, true
+}
+
+// endregion
+
+// region "compareNotificationIDs" orders two parsed stream IDs
+func compareNotificationIDs(a, b [2]uint64) int {
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// endregion
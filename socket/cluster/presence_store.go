@@ -0,0 +1,361 @@
+package cluster
+
+import (
+	"context"
+	"github.com/gomodule/redigo/redis"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	PresenceTTL             = time.Minute      // How long a socket counts as connected without being refreshed.
+	presenceRefreshInterval = 20 * time.Second // How often an instance refreshes its own sockets.
+	presenceKeyPrefix       = "presence:"
+	presenceUsersKey        = "presence_users"      // Online users scored by the expiry of their latest socket.
+	presenceHeartbeatsKey   = "presence_heartbeats" // Online users scored by their last client heartbeat.
+	presenceIdleKey         = "presence_idle"       // Online users whose heartbeats stopped.
+)
+
+// addConnectionScript registers a socket, drops expired ones, counts the connection as activity and returns the number of live sockets of the user.
+var addConnectionScript = redis.NewScript(3, `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[5])
+redis.call('ZADD', KEYS[3], ARGV[1], ARGV[5])
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// removeConnectionScript unregisters a socket, drops expired ones and returns the number of live sockets left.
+// Once none are left, the user's heartbeat and idle state are dropped too.
+var removeConnectionScript = redis.NewScript(4, `
+redis.call('ZREM', KEYS[1], ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+local count = redis.call('ZCARD', KEYS[1])
+if count == 0 then
+	redis.call('ZREM', KEYS[2], ARGV[3])
+	redis.call('ZREM', KEYS[3], ARGV[3])
+	redis.call('SREM', KEYS[4], ARGV[3])
+end
+return count
+`)
+
+// heartbeatScript records the user's activity and returns 1 if the user was idle.
+var heartbeatScript = redis.NewScript(2, `
+redis.call('ZADD', KEYS[1], ARGV[1], ARGV[2])
+return redis.call('SREM', KEYS[2], ARGV[2])
+`)
+
+// markIdleScript flags the users whose last heartbeat is not after the threshold and returns the newly idle ones.
+var markIdleScript = redis.NewScript(2, `
+local newlyIdle = {}
+for _, email in ipairs(redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])) do
+	if redis.call('SADD', KEYS[2], email) == 1 then
+		table.insert(newlyIdle, email)
+	end
+end
+return newlyIdle
+`)
+
+// reapExpiredScript drops the users whose sockets all expired, such as those of a crashed instance, and returns them.
+// Users with a socket refreshed by another instance get the expiry of their latest socket back.
+var reapExpiredScript = redis.NewScript(3, `
+local reaped = {}
+for _, email in ipairs(redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])) do
+	local key = ARGV[2] .. email
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', ARGV[1])
+	local latest = redis.call('ZREVRANGE', key, 0, 0, 'WITHSCORES')
+	if #latest == 0 then
+		redis.call('ZREM', KEYS[1], email)
+		redis.call('ZREM', KEYS[2], email)
+		redis.call('SREM', KEYS[3], email)
+		table.insert(reaped, email)
+	else
+		redis.call('ZADD', KEYS[1], latest[2], email)
+	end
+end
+return reaped
+`)
+
+type IPresenceStore interface {
+	AddConnection(userEmail, socketId string) (bool, error)
+	RemoveConnection(userEmail, socketId string) (bool, error)
+	IsOnline(userEmail string) (bool, error)
+	OnlineUsers(userEmails []string) (map[string]bool, error)
+	Heartbeat(userEmail string) (bool, error)
+	MarkIdle(threshold time.Time) ([]string, error)
+	IsIdle(userEmail string) (bool, error)
+	IdleUsers(userEmails []string) (map[string]bool, error)
+	ReapExpired() ([]string, error)
+	Close()
+}
+
+// presenceStore keeps every user's connected sockets, across all instances, in a Redis sorted set scored by expiry.
+// Each instance refreshes the expiry of its own sockets, so the sockets of a crashed instance expire on their own.
+// Heartbeats and idle flags are shared the same way, so every instance sees the same idle state.
+type presenceStore struct {
+	ctx       context.Context // Carries the logger of the background refresh
+	pool      *redis.Pool
+	mux       sync.Mutex
+	sockets   map[string]map[string]struct{} // Sockets connected to this instance per user email, guarded by mux
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewPresenceStore(ctx context.Context, pool *redis.Pool) IPresenceStore {
+	store := &presenceStore{
+		ctx:     ctx,
+		pool:    pool,
+		sockets: make(map[string]map[string]struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go store.refreshLoop()
+	return store
+}
+
+// region "AddConnection" records a connected socket and reports whether it is the user's first one across all instances
+func (s *presenceStore) AddConnection(userEmail, socketId string) (bool, error) {
+	s.mux.Lock()
+	if s.sockets[userEmail] == nil {
+		s.sockets[userEmail] = make(map[string]struct{})
+	}
+	s.sockets[userEmail][socketId] = struct{}{}
+	s.mux.Unlock()
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	now := time.Now()
+	count, err := redis.Int(addConnectionScript.Do(conn, presenceKey(userEmail), presenceUsersKey, presenceHeartbeatsKey,
+		toMillis(now), toMillis(now.Add(PresenceTTL)), socketId, PresenceTTL.Milliseconds(), userEmail))
+	if err != nil {
+		return false, err
+	}
+	return count == 1, nil
+}
+
+// endregion
+
+// region "RemoveConnection" forgets a disconnected socket and reports whether it was the user's last one across all instances
+func (s *presenceStore) RemoveConnection(userEmail, socketId string) (bool, error) {
+	s.mux.Lock()
+	delete(s.sockets[userEmail], socketId)
+	if len(s.sockets[userEmail]) == 0 {
+		delete(s.sockets, userEmail)
+	}
+	s.mux.Unlock()
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(removeConnectionScript.Do(conn, presenceKey(userEmail), presenceUsersKey, presenceHeartbeatsKey, presenceIdleKey,
+		toMillis(time.Now()), socketId, userEmail))
+	if err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// endregion
+
+// region "IsOnline" reports whether the user has a live socket on any instance
+func (s *presenceStore) IsOnline(userEmail string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	count, err := redis.Int(conn.Do("ZCOUNT", presenceKey(userEmail), toMillis(time.Now()), "+inf"))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// endregion
+
+// region "OnlineUsers" reports, in a single pipeline, which of the users have a live socket on any instance
+func (s *presenceStore) OnlineUsers(userEmails []string) (map[string]bool, error) {
+	online := make(map[string]bool, len(userEmails))
+	if len(userEmails) == 0 {
+		return online, nil
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	now := toMillis(time.Now())
+	for _, userEmail := range userEmails {
+		if err := conn.Send("ZCOUNT", presenceKey(userEmail), now, "+inf"); err != nil {
+			return nil, err
+		}
+	}
+
+	counts, err := redis.Ints(conn.Do(""))
+	if err != nil {
+		return nil, err
+	}
+	for i, userEmail := range userEmails {
+		online[userEmail] = counts[i] > 0
+	}
+	return online, nil
+}
+
+// endregion
+
+// region "Heartbeat" records client activity of the user and reports whether the user was idle until now
+func (s *presenceStore) Heartbeat(userEmail string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	wasIdle, err := redis.Int(heartbeatScript.Do(conn, presenceHeartbeatsKey, presenceIdleKey, toMillis(time.Now()), userEmail))
+	if err != nil {
+		return false, err
+	}
+	return wasIdle == 1, nil
+}
+
+// endregion
+
+// region "MarkIdle" flags online users without a heartbeat since the threshold as idle and returns the newly idle ones
+// Each user is returned by a single call, whichever instance makes it.
+func (s *presenceStore) MarkIdle(threshold time.Time) ([]string, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(markIdleScript.Do(conn, presenceHeartbeatsKey, presenceIdleKey, toMillis(threshold)))
+}
+
+// endregion
+
+// region "IsIdle" reports whether the online user stopped sending heartbeats
+func (s *presenceStore) IsIdle(userEmail string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("SISMEMBER", presenceIdleKey, userEmail))
+}
+
+// endregion
+
+// region "IdleUsers" reports, in a single pipeline, which of the users stopped sending heartbeats
+func (s *presenceStore) IdleUsers(userEmails []string) (map[string]bool, error) {
+	idle := make(map[string]bool, len(userEmails))
+	if len(userEmails) == 0 {
+		return idle, nil
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	for _, userEmail := range userEmails {
+		if err := conn.Send("SISMEMBER", presenceIdleKey, userEmail); err != nil {
+			return nil, err
+		}
+	}
+
+	flags, err := redis.Ints(conn.Do(""))
+	if err != nil {
+		return nil, err
+	}
+	for i, userEmail := range userEmails {
+		idle[userEmail] = flags[i] == 1
+	}
+	return idle, nil
+}
+
+// endregion
+
+// region "ReapExpired" drops the users whose sockets all expired without being removed and returns them
+// Each user is returned by a single call, whichever instance makes it.
+func (s *presenceStore) ReapExpired() ([]string, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return redis.Strings(reapExpiredScript.Do(conn, presenceUsersKey, presenceHeartbeatsKey, presenceIdleKey,
+		toMillis(time.Now()), presenceKeyPrefix))
+}
+
+// endregion
+
+// region "Close" stops refreshing this instance's sockets
+func (s *presenceStore) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// endregion
+
+// region "refreshLoop" periodically extends the expiry of the sockets connected to this instance
+func (s *presenceStore) refreshLoop() {
+	ticker := time.NewTicker(presenceRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			if err := s.refresh(); err != nil {
+				logging.FromContext(s.ctx).Error("failed to refresh presence", "error", err)
+			}
+		}
+	}
+}
+
+// endregion
+
+// region "refresh" extends the expiry of the sockets connected to this instance in a single pipeline
+func (s *presenceStore) refresh() error {
+	s.mux.Lock()
+	sockets := make(map[string][]string, len(s.sockets))
+	for userEmail, socketIds := range s.sockets {
+		for socketId := range socketIds {
+			sockets[userEmail] = append(sockets[userEmail], socketId)
+		}
+	}
+	s.mux.Unlock()
+
+	if len(sockets) == 0 {
+		return nil
+	}
+
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	expiry := toMillis(time.Now().Add(PresenceTTL))
+	for userEmail, socketIds := range sockets {
+		for _, socketId := range socketIds {
+			if err := conn.Send("ZADD", presenceKey(userEmail), expiry, socketId); err != nil {
+				return err
+			}
+		}
+		if err := conn.Send("PEXPIRE", presenceKey(userEmail), PresenceTTL.Milliseconds()); err != nil {
+			return err
+		}
+		if err := conn.Send("ZADD", presenceUsersKey, expiry, userEmail); err != nil {
+			return err
+		}
+	}
+
+	_, err := conn.Do("")
+	return err
+}
+
+// endregion
+
+// region "presenceKey" returns the Redis key holding the user's sockets
+func presenceKey(userEmail string) string {
+	return presenceKeyPrefix + userEmail
+}
+
+// endregion
+
+// region "toMillis" formats a time as a Unix millisecond score
+func toMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// endregion
@@ -0,0 +1,272 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+// newTestPool returns a Redis pool backed by an in-memory server that is stopped with the test.
+func newTestPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", server.Addr())
+		},
+	}
+	t.Cleanup(func() { pool.Close() })
+	return server, pool
+}
+
+// newTestPresenceStore returns a presence store, as one instance would create it, closed with the test.
+func newTestPresenceStore(t *testing.T, pool *redis.Pool) IPresenceStore {
+	t.Helper()
+
+	store := NewPresenceStore(context.Background(), pool)
+	t.Cleanup(store.Close)
+	return store
+}
+
+func TestAddConnectionReportsFirstSocketAcrossInstances(t *testing.T) {
+	_, pool := newTestPool(t)
+	first, second := newTestPresenceStore(t, pool), newTestPresenceStore(t, pool)
+
+	isFirst, err := first.AddConnection("user@example.com", "socket-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFirst {
+		t.Error("first socket of the user was not reported as the first one")
+	}
+
+	isFirst, err = second.AddConnection("user@example.com", "socket-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isFirst {
+		t.Error("socket on another instance was reported as the first one")
+	}
+
+	isFirst, err = second.AddConnection("other@example.com", "socket-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFirst {
+		t.Error("first socket of another user was not reported as the first one")
+	}
+}
+
+func TestRemoveConnectionReportsLastSocketAcrossInstances(t *testing.T) {
+	_, pool := newTestPool(t)
+	first, second := newTestPresenceStore(t, pool), newTestPresenceStore(t, pool)
+
+	for store, socketId := range map[IPresenceStore]string{first: "socket-1", second: "socket-2"} {
+		if _, err := store.AddConnection("user@example.com", socketId); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	isLast, err := first.RemoveConnection("user@example.com", "socket-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isLast {
+		t.Error("socket was reported as the last one while another instance still has one")
+	}
+	if online, _ := first.IsOnline("user@example.com"); !online {
+		t.Error("user with a socket on another instance is not online")
+	}
+
+	isLast, err = second.RemoveConnection("user@example.com", "socket-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isLast {
+		t.Error("last socket of the user was not reported as the last one")
+	}
+	if online, _ := first.IsOnline("user@example.com"); online {
+		t.Error("user without sockets is still online")
+	}
+}
+
+func TestExpiredSocketsAreNotCounted(t *testing.T) {
+	server, pool := newTestPool(t)
+	store := newTestPresenceStore(t, pool)
+
+	// A socket of a crashed instance whose expiry was never refreshed.
+	expired := float64(time.Now().Add(-time.Second).UnixMilli())
+	if _, err := server.ZAdd(presenceKey("user@example.com"), expired, "crashed-socket"); err != nil {
+		t.Fatal(err)
+	}
+
+	if online, _ := store.IsOnline("user@example.com"); online {
+		t.Error("user with only an expired socket is online")
+	}
+
+	isFirst, err := store.AddConnection("user@example.com", "socket-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFirst {
+		t.Error("expired socket was counted when adding a new one")
+	}
+
+	isLast, err := store.RemoveConnection("user@example.com", "socket-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isLast {
+		t.Error("expired socket was counted when removing the last one")
+	}
+}
+
+func TestOnlineUsersChecksEveryUserAtOnce(t *testing.T) {
+	server, pool := newTestPool(t)
+	first, second := newTestPresenceStore(t, pool), newTestPresenceStore(t, pool)
+
+	if _, err := first.AddConnection("first@example.com", "socket-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.AddConnection("second@example.com", "socket-2"); err != nil {
+		t.Fatal(err)
+	}
+	expired := float64(time.Now().Add(-time.Second).UnixMilli())
+	if _, err := server.ZAdd(presenceKey("crashed@example.com"), expired, "crashed-socket"); err != nil {
+		t.Fatal(err)
+	}
+
+	online, err := first.OnlineUsers([]string{"first@example.com", "second@example.com", "crashed@example.com", "offline@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{
+		"first@example.com":   true,
+		"second@example.com":  true,
+		"crashed@example.com": false,
+		"offline@example.com": false,
+	}
+	for userEmail, wantOnline := range want {
+		if got, ok := online[userEmail]; !ok || got != wantOnline {
+			t.Errorf("online[%q] = %v, %v, want %v", userEmail, got, ok, wantOnline)
+		}
+	}
+}
+
+func TestPresenceKeyExpiresWithoutRefresh(t *testing.T) {
+	server, pool := newTestPool(t)
+	store := newTestPresenceStore(t, pool)
+
+	if _, err := store.AddConnection("user@example.com", "socket-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	server.FastForward(PresenceTTL + time.Second)
+	if server.Exists(presenceKey("user@example.com")) {
+		t.Error("presence of an instance that stopped refreshing did not expire")
+	}
+}
+
+func TestIdleStateIsSharedAcrossInstances(t *testing.T) {
+	_, pool := newTestPool(t)
+	first, second := newTestPresenceStore(t, pool), newTestPresenceStore(t, pool)
+
+	if _, err := first.AddConnection("user@example.com", "socket-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A threshold after the connection makes its heartbeat stale.
+	threshold := time.Now().Add(time.Second)
+	newlyIdle, err := first.MarkIdle(threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newlyIdle) != 1 || newlyIdle[0] != "user@example.com" {
+		t.Fatalf("newly idle users = %v, want [user@example.com]", newlyIdle)
+	}
+
+	if newlyIdle, _ = second.MarkIdle(threshold); len(newlyIdle) != 0 {
+		t.Errorf("idle user was reported again by another instance: %v", newlyIdle)
+	}
+	if idle, _ := second.IsIdle("user@example.com"); !idle {
+		t.Error("user marked idle by one instance is not idle on another")
+	}
+
+	wasIdle, err := second.Heartbeat("user@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wasIdle {
+		t.Error("heartbeat of an idle user did not report the user as idle")
+	}
+	if idle, _ := first.IsIdle("user@example.com"); idle {
+		t.Error("heartbeat on one instance did not clear the idle state on another")
+	}
+}
+
+func TestRemovingLastSocketClearsIdleState(t *testing.T) {
+	_, pool := newTestPool(t)
+	store := newTestPresenceStore(t, pool)
+
+	if _, err := store.AddConnection("user@example.com", "socket-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.MarkIdle(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RemoveConnection("user@example.com", "socket-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if idle, _ := store.IsIdle("user@example.com"); idle {
+		t.Error("offline user is still idle")
+	}
+	if reaped, _ := store.ReapExpired(); len(reaped) != 0 {
+		t.Errorf("user who disconnected was reaped: %v", reaped)
+	}
+}
+
+func TestReapExpiredReportsUsersOfCrashedInstancesOnce(t *testing.T) {
+	server, pool := newTestPool(t)
+	first, second := newTestPresenceStore(t, pool), newTestPresenceStore(t, pool)
+
+	for _, userEmail := range []string{"crashed@example.com", "moved@example.com"} {
+		if _, err := first.AddConnection(userEmail, "socket-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := second.AddConnection("moved@example.com", "socket-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first instance crashes: its sockets are never refreshed and expire.
+	expired := float64(time.Now().Add(-time.Second).UnixMilli())
+	for _, userEmail := range []string{"crashed@example.com", "moved@example.com"} {
+		if _, err := server.ZAdd(presenceKey(userEmail), expired, "socket-1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := server.ZAdd(presenceUsersKey, expired, userEmail); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reaped, err := second.ReapExpired()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reaped) != 1 || reaped[0] != "crashed@example.com" {
+		t.Fatalf("reaped users = %v, want [crashed@example.com]", reaped)
+	}
+
+	if reaped, _ = first.ReapExpired(); len(reaped) != 0 {
+		t.Errorf("offline user was reaped again: %v", reaped)
+	}
+	if online, _ := second.IsOnline("moved@example.com"); !online {
+		t.Error("user with a socket on a live instance is not online")
+	}
+}
@@ -0,0 +1,355 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gomodule/redigo/redis"
+	"github.com/google/uuid"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/socket.io-go-parser/parser"
+	"github.com/zishang520/socket.io/socket"
+	"sync"
+	"time"
+)
+
+const resubscribeDelay = time.Second // Wait before resubscribing after the Redis connection drops.
+
+type messageType string
+
+const (
+	broadcastMessage         messageType = "broadcast"
+	addSocketsMessage        messageType = "add_sockets"
+	delSocketsMessage        messageType = "del_sockets"
+	disconnectSocketsMessage messageType = "disconnect_sockets"
+)
+
+// region clusterMessage is the payload exchanged between instances over Redis pub/sub.
+type clusterMessage struct {
+	Uid      string         `json:"uid"` // Instance that published the message, so it can skip its own
+	Type     messageType    `json:"type"`
+	Packet   *parser.Packet `json:"packet,omitempty"`
+	Rooms    []socket.Room  `json:"rooms,omitempty"`
+	Except   []socket.Room  `json:"except,omitempty"`
+	Volatile bool           `json:"volatile,omitempty"`
+	Compress bool           `json:"compress,omitempty"`
+	Targets  []socket.Room  `json:"targets,omitempty"` // Rooms to join or leave
+	Close    bool           `json:"close,omitempty"`   // Whether disconnected sockets close the underlying connection
+}
+
+// endregion
+
+// region RedisAdapterBuilder creates Redis-backed adapters so room emits reach sockets on every instance.
+type RedisAdapterBuilder struct {
+	Pool   *redis.Pool
+	Prefix string          // Prefix of the pub/sub channels, one channel per namespace
+	Ctx    context.Context // Carries the logger of the adapters, which relay operations outside of any request
+
+	uid      string
+	mux      sync.Mutex
+	adapters []*redisAdapter
+}
+
+func NewRedisAdapterBuilder(ctx context.Context, pool *redis.Pool, prefix string) *RedisAdapterBuilder {
+	return &RedisAdapterBuilder{
+		Pool:   pool,
+		Prefix: prefix,
+		Ctx:    ctx,
+		uid:    uuid.NewString(),
+	}
+}
+
+// endregion
+
+// region "New" creates the adapter of a namespace and starts listening to the other instances.
+func (b *RedisAdapterBuilder) New(nsp socket.NamespaceInterface) socket.Adapter {
+	channel := b.Prefix + "#" + nsp.Name() + "#"
+	adapter := &redisAdapter{
+		Adapter: (&socket.AdapterBuilder{}).New(nsp),
+		ctx:     logging.WithLogger(b.Ctx, logging.FromContext(b.Ctx).With("channel", channel)), // Every log of the adapter names its channel
+		pool:    b.Pool,
+		uid:     b.uid,
+		channel: channel,
+		done:    make(chan struct{}),
+	}
+
+	b.mux.Lock()
+	b.adapters = append(b.adapters, adapter)
+	b.mux.Unlock()
+
+	go adapter.subscribe()
+	return adapter
+}
+
+// endregion
+
+// region "Close" stops the Redis subscriptions of every adapter created by the builder.
+func (b *RedisAdapterBuilder) Close() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, adapter := range b.adapters {
+		adapter.Close()
+	}
+}
+
+// endregion
+
+// redisAdapter keeps local room bookkeeping in the in-memory adapter and relays operations to other instances.
+type redisAdapter struct {
+	socket.Adapter
+
+	ctx     context.Context // Carries the logger of the adapter
+	pool    *redis.Pool
+	uid     string
+	channel string
+
+	mux       sync.Mutex
+	pubSub    *redis.PubSubConn
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// region "Close" stops listening to the other instances.
+func (a *redisAdapter) Close() {
+	a.closeOnce.Do(func() {
+		close(a.done)
+
+		// Unsubscribing ends the pending Receive once Redis confirms. Closing the pooled connection here instead
+		// would wait for that confirmation itself, racing the Receive for it and hanging forever.
+		a.mux.Lock()
+		if a.pubSub != nil {
+			a.pubSub.Unsubscribe()
+		}
+		a.mux.Unlock()
+
+		a.Adapter.Close()
+	})
+}
+
+// endregion
+
+// region "ServerCount" returns the number of instances subscribed to the namespace channel.
+func (a *redisAdapter) ServerCount() int64 {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	reply, err := redis.Values(conn.Do("PUBSUB", "NUMSUB", a.channel))
+	if err != nil || len(reply) < 2 {
+		return 1
+	}
+
+	count, err := redis.Int64(reply[1], nil)
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
+}
+
+// endregion
+
+// region "Broadcast" sends a packet to the matching local sockets and forwards it to the other instances.
+func (a *redisAdapter) Broadcast(packet *parser.Packet, opts *socket.BroadcastOptions) {
+	if !isLocal(opts) {
+		message := a.newMessage(broadcastMessage, opts)
+		message.Packet = packet
+		a.publish(message)
+	}
+
+	a.Adapter.Broadcast(packet, opts)
+}
+
+// endregion
+
+// region "AddSockets" makes the matching sockets on every instance join the given rooms.
+func (a *redisAdapter) AddSockets(opts *socket.BroadcastOptions, rooms []socket.Room) {
+	if !isLocal(opts) {
+		message := a.newMessage(addSocketsMessage, opts)
+		message.Targets = rooms
+		a.publish(message)
+	}
+
+	a.Adapter.AddSockets(opts, rooms)
+}
+
+// endregion
+
+// region "DelSockets" makes the matching sockets on every instance leave the given rooms.
+func (a *redisAdapter) DelSockets(opts *socket.BroadcastOptions, rooms []socket.Room) {
+	if !isLocal(opts) {
+		message := a.newMessage(delSocketsMessage, opts)
+		message.Targets = rooms
+		a.publish(message)
+	}
+
+	a.Adapter.DelSockets(opts, rooms)
+}
+
+// endregion
+
+// region "DisconnectSockets" disconnects the matching sockets on every instance.
+func (a *redisAdapter) DisconnectSockets(opts *socket.BroadcastOptions, status bool) {
+	if !isLocal(opts) {
+		message := a.newMessage(disconnectSocketsMessage, opts)
+		message.Close = status
+		a.publish(message)
+	}
+
+	a.Adapter.DisconnectSockets(opts, status)
+}
+
+// endregion
+
+// region "newMessage" builds a cluster message carrying the broadcast options.
+func (a *redisAdapter) newMessage(kind messageType, opts *socket.BroadcastOptions) *clusterMessage {
+	message := &clusterMessage{Uid: a.uid, Type: kind}
+	if opts == nil {
+		return message
+	}
+
+	if opts.Rooms != nil {
+		message.Rooms = opts.Rooms.Keys()
+	}
+	if opts.Except != nil {
+		message.Except = opts.Except.Keys()
+	}
+	if opts.Flags != nil {
+		message.Volatile = opts.Flags.Volatile
+		message.Compress = opts.Flags.Compress
+	}
+	return message
+}
+
+// endregion
+
+// region "publish" sends a cluster message to the other instances.
+func (a *redisAdapter) publish(message *clusterMessage) {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		logging.FromContext(a.ctx).Error("failed to encode cluster message", "error", err)
+		return
+	}
+
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("PUBLISH", a.channel, payload); err != nil {
+		logging.FromContext(a.ctx).Error("failed to publish cluster message", "error", err)
+	}
+}
+
+// endregion
+
+// region "subscribe" listens to the namespace channel until the adapter is closed, resubscribing after connection errors.
+func (a *redisAdapter) subscribe() {
+	for {
+		select {
+		case <-a.done:
+			return
+		default:
+		}
+
+		pubSub := &redis.PubSubConn{Conn: a.pool.Get()}
+		if err := pubSub.Subscribe(a.channel); err != nil {
+			logging.FromContext(a.ctx).Error("failed to subscribe to cluster channel", "error", err)
+			pubSub.Close()
+			time.Sleep(resubscribeDelay)
+			continue
+		}
+
+		a.mux.Lock()
+		select {
+		case <-a.done:
+			// Closed while subscribing: nobody is left to unsubscribe this connection.
+			a.mux.Unlock()
+			pubSub.Close()
+			return
+		default:
+		}
+		a.pubSub = pubSub
+		a.mux.Unlock()
+
+		a.receive(pubSub)
+
+		// Close under the lock so it never writes to the connection at the same time as Close's Unsubscribe.
+		a.mux.Lock()
+		a.pubSub = nil
+		pubSub.Close()
+		a.mux.Unlock()
+
+		select {
+		case <-a.done:
+			return
+		case <-time.After(resubscribeDelay):
+		}
+	}
+}
+
+// endregion
+
+// region "receive" applies the messages of the other instances until the adapter unsubscribes or the subscription fails.
+func (a *redisAdapter) receive(pubSub *redis.PubSubConn) {
+	for {
+		switch reply := pubSub.Receive().(type) {
+		case redis.Message:
+			a.handleMessage(reply.Data)
+		case redis.Subscription:
+			if reply.Count == 0 {
+				return // Unsubscribed by Close.
+			}
+		case error:
+			select {
+			case <-a.done:
+			default:
+				logging.FromContext(a.ctx).Error("cluster subscription failed", "error", reply)
+			}
+			return
+		}
+	}
+}
+
+// endregion
+
+// region "handleMessage" applies an operation published by another instance to the local sockets.
+func (a *redisAdapter) handleMessage(payload []byte) {
+	var message clusterMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		logging.FromContext(a.ctx).Error("failed to decode cluster message", "error", err)
+		return
+	}
+
+	// Our own messages were already applied locally.
+	if message.Uid == a.uid {
+		return
+	}
+
+	opts := &socket.BroadcastOptions{
+		Rooms:  types.NewSet(message.Rooms...),
+		Except: types.NewSet(message.Except...),
+		Flags:  &socket.BroadcastFlags{Local: true},
+	}
+	opts.Flags.Volatile = message.Volatile
+	opts.Flags.Compress = message.Compress
+
+	switch message.Type {
+	case broadcastMessage:
+		if message.Packet != nil {
+			a.Adapter.Broadcast(message.Packet, opts)
+		}
+	case addSocketsMessage:
+		a.Adapter.AddSockets(opts, message.Targets)
+	case delSocketsMessage:
+		a.Adapter.DelSockets(opts, message.Targets)
+	case disconnectSocketsMessage:
+		a.Adapter.DisconnectSockets(opts, message.Close)
+	}
+}
+
+// endregion
+
+// region "isLocal" reports whether the operation is restricted to the current instance.
+func isLocal(opts *socket.BroadcastOptions) bool {
+	return opts != nil && opts.Flags != nil && opts.Flags.Local
+}
+
+// endregion
@@ -0,0 +1,168 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/socket.io-go-parser/parser"
+	"github.com/zishang520/socket.io/socket"
+)
+
+// recordingAdapter stands in for the in-memory adapter and records the broadcasts applied to the local sockets.
+type recordingAdapter struct {
+	socket.Adapter
+
+	mux        sync.Mutex
+	broadcasts []*socket.BroadcastOptions
+}
+
+func (r *recordingAdapter) Broadcast(_ *parser.Packet, opts *socket.BroadcastOptions) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.broadcasts = append(r.broadcasts, opts)
+}
+
+func (r *recordingAdapter) Close() {}
+
+func (r *recordingAdapter) broadcastCount() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return len(r.broadcasts)
+}
+
+// newTestRedisAdapter returns an adapter of the instance with the given uid, recording what reaches its local sockets.
+func newTestRedisAdapter(pool *redis.Pool, uid string) (*redisAdapter, *recordingAdapter) {
+	local := &recordingAdapter{}
+	return &redisAdapter{
+		Adapter: local,
+		ctx:     context.Background(),
+		pool:    pool,
+		uid:     uid,
+		channel: "socket.io#/chat#",
+		done:    make(chan struct{}),
+	}, local
+}
+
+// waitFor polls the condition until it holds or a second has passed.
+func waitFor(t *testing.T, condition func() bool) bool {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
+
+func TestHandleMessageSkipsOwnMessages(t *testing.T) {
+	adapter, local := newTestRedisAdapter(nil, "instance-1")
+
+	payload, err := json.Marshal(&clusterMessage{
+		Uid:    "instance-1",
+		Type:   broadcastMessage,
+		Packet: &parser.Packet{Type: parser.EVENT, Nsp: "/chat", Data: []any{"new_message"}},
+		Rooms:  []socket.Room{"room"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter.handleMessage(payload)
+	if count := local.broadcastCount(); count != 0 {
+		t.Errorf("own message was applied %d times, want 0", count)
+	}
+}
+
+func TestHandleMessageAppliesOtherInstancesMessagesLocally(t *testing.T) {
+	adapter, local := newTestRedisAdapter(nil, "instance-1")
+
+	payload, err := json.Marshal(&clusterMessage{
+		Uid:    "instance-2",
+		Type:   broadcastMessage,
+		Packet: &parser.Packet{Type: parser.EVENT, Nsp: "/chat", Data: []any{"new_message"}},
+		Rooms:  []socket.Room{"room"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adapter.handleMessage(payload)
+	if count := local.broadcastCount(); count != 1 {
+		t.Fatalf("message of another instance was applied %d times, want 1", count)
+	}
+
+	opts := local.broadcasts[0]
+	if !opts.Flags.Local {
+		t.Error("relayed broadcast is not local, so it would be published again")
+	}
+	if !opts.Rooms.Has("room") {
+		t.Errorf("relayed broadcast targets %v, want room", opts.Rooms.Keys())
+	}
+}
+
+func TestBroadcastReachesOtherInstances(t *testing.T) {
+	_, pool := newTestPool(t)
+	sender, senderLocal := newTestRedisAdapter(pool, "instance-1")
+	receiver, receiverLocal := newTestRedisAdapter(pool, "instance-2")
+	for _, adapter := range []*redisAdapter{sender, receiver} {
+		go adapter.subscribe()
+		t.Cleanup(adapter.Close)
+	}
+
+	if !waitFor(t, func() bool { return sender.ServerCount() == 2 }) {
+		t.Fatal("instances did not subscribe to the namespace channel")
+	}
+
+	packet := &parser.Packet{Type: parser.EVENT, Nsp: "/chat", Data: []any{"new_message"}}
+	sender.Broadcast(packet, &socket.BroadcastOptions{Rooms: types.NewSet[socket.Room]("room")})
+
+	if !waitFor(t, func() bool { return receiverLocal.broadcastCount() == 1 }) {
+		t.Fatalf("other instance applied the broadcast %d times, want 1", receiverLocal.broadcastCount())
+	}
+	if count := senderLocal.broadcastCount(); count != 1 {
+		t.Errorf("sending instance applied the broadcast %d times, want 1", count)
+	}
+}
+
+func TestCloseStopsSubscription(t *testing.T) {
+	server, pool := newTestPool(t)
+	adapter, _ := newTestRedisAdapter(pool, "instance-1")
+
+	stopped := make(chan struct{})
+	go func() {
+		adapter.subscribe()
+		close(stopped)
+	}()
+
+	if !waitFor(t, func() bool { return server.PubSubNumSub(adapter.channel)[adapter.channel] == 1 }) {
+		t.Fatal("instance did not subscribe to the namespace channel")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		adapter.Close()
+		close(closed)
+	}()
+
+	for name, done := range map[string]chan struct{}{"Close": closed, "subscription": stopped} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s did not stop", name)
+		}
+	}
+
+	if count := server.PubSubNumSub(adapter.channel)[adapter.channel]; count != 0 {
+		t.Errorf("instance is still subscribed %d times after closing", count)
+	}
+}
@@ -1,6 +1,9 @@
 package gateway
 
 import (
+	"context"
+	"github.com/kwa0x2/swiftchat-backend/internal/logging"
+	"github.com/kwa0x2/swiftchat-backend/socket/cluster"
 	"github.com/zishang520/socket.io/socket"
 )
 
@@ -9,19 +12,49 @@ type ISocketGateway interface {
 	EmitRoom(room, event string, data interface{})
 	JoinRoom(socketio *socket.Socket, room string)
 	Emit(event string, data interface{})
-	EmitToNotificationRoom(notifyAction, receiverMail string, notifyObj any)
-	EmitToRoomId(notifyAction, roomId string, notifyObj any)
+	EmitToNotificationRoom(ctx context.Context, notifyAction, receiverMail string, notifyObj any)
+	EmitToRoomId(ctx context.Context, notifyAction, roomId string, notifyObj any)
+	RemoveUserFromRoom(userId, room string)
+	AddUserToRoom(userId, room string)
+	CloseRoom(room string)
+	EmitToChannel(notifyAction, roomId string, notifyObj any)
+	NotifyShutdown()
 }
 
+// region "UserRoom" returns the name of the private socket.io room every socket of a user joins.
+func UserRoom(userId string) string {
+	return "user:" + userId
+}
+
+// endregion
+
+// region "NotificationRoom" returns the name of the socket.io room every socket of a user joins to receive their notifications.
+func NotificationRoom(userEmail string) string {
+	return "notification:" + userEmail
+}
+
+// endregion
+
+// region "ChannelRoom" returns the name of the socket.io room joined by every socket of a channel's subscribers.
+func ChannelRoom(roomId string) string {
+	return "channel:" + roomId
+}
+
+// endregion
+
 type socketGateway struct {
-	Server    *socket.Server
-	namespace string
+	Server        *socket.Server
+	EventLog      cluster.IEventLog
+	Notifications cluster.INotificationStream
+	namespace     string
 }
 
-func NewSocketGateway(server *socket.Server, namespace string) ISocketGateway {
+func NewSocketGateway(server *socket.Server, namespace string, eventLog cluster.IEventLog, notifications cluster.INotificationStream) ISocketGateway {
 	return &socketGateway{
-		Server:    server,
-		namespace: namespace,
+		Server:        server,
+		EventLog:      eventLog,
+		Notifications: notifications,
+		namespace:     namespace,
 	}
 }
 
@@ -58,25 +91,81 @@ func (g *socketGateway) JoinRoom(socketio *socket.Socket, room string) {
 // endregion
 
 // region "EmitToNotificationRoom" sends a notification action with data to a specific user's notification room.
-func (g *socketGateway) EmitToNotificationRoom(notifyAction, receiverMail string, notifyObj any) {
+// The notification is also added to the user's notification stream, which serves the SSE fallback.
+func (g *socketGateway) EmitToNotificationRoom(ctx context.Context, notifyAction, receiverMail string, notifyObj any) {
 	data := map[string]interface{}{
 		"action": notifyAction,
 		"data":   notifyObj,
 	}
 
-	g.EmitRoom("notification", receiverMail, data)
+	if _, err := g.Notifications.Append(receiverMail, notifyAction, notifyObj); err != nil {
+		logging.FromContext(ctx).Error("failed to stream notification", "action", notifyAction, "receiver", receiverMail, "error", err)
+	}
+
+	// Only the receiver's sockets are in their room; the event keeps the receiver's email as its name.
+	g.EmitRoom(NotificationRoom(receiverMail), receiverMail, data)
 }
 
 // endregion
 
 // region "EmitToRoomId" sends a notification action with data to a specific room by room ID.
-func (g *socketGateway) EmitToRoomId(notifyAction, roomId string, notifyObj any) {
+// Each event is numbered and logged per room so reconnecting clients can replay the ones they missed.
+func (g *socketGateway) EmitToRoomId(ctx context.Context, notifyAction, roomId string, notifyObj any) {
+	data := map[string]interface{}{
+		"action": notifyAction,
+		"data":   notifyObj,
+	}
+
+	if seq, err := g.EventLog.Append(roomId, notifyAction, notifyObj); err != nil {
+		logging.FromContext(ctx).Error("failed to log room event", "action", notifyAction, "room_id", roomId, "error", err)
+	} else {
+		data["seq"] = seq
+	}
+
+	// Only the sockets that joined the room receive the event, which keeps the room ID as its name.
+	g.EmitRoom(roomId, roomId, data)
+}
+
+// endregion
+
+// region "RemoveUserFromRoom" makes every socket of a user leave the specified room.
+func (g *socketGateway) RemoveUserFromRoom(userId, room string) {
+	g.Server.Of(g.namespace, nil).In(socket.Room(UserRoom(userId))).SocketsLeave(socket.Room(room))
+}
+
+// endregion
+
+// region "AddUserToRoom" makes every socket of a user join the specified room.
+func (g *socketGateway) AddUserToRoom(userId, room string) {
+	g.Server.Of(g.namespace, nil).In(socket.Room(UserRoom(userId))).SocketsJoin(socket.Room(room))
+}
+
+// endregion
+
+// region "CloseRoom" makes every socket currently in the specified room leave it.
+func (g *socketGateway) CloseRoom(room string) {
+	g.Server.Of(g.namespace, nil).In(socket.Room(room)).SocketsLeave(socket.Room(room))
+}
+
+// endregion
+
+// region "EmitToChannel" sends a notification action with data to every subscriber of a channel in a single broadcast.
+func (g *socketGateway) EmitToChannel(notifyAction, roomId string, notifyObj any) {
 	data := map[string]interface{}{
 		"action": notifyAction,
 		"data":   notifyObj,
 	}
 
-	g.Emit(roomId, data)
+	g.EmitRoom(ChannelRoom(roomId), "channel", data)
+}
+
+// endregion
+
+// region "NotifyShutdown" tells the sockets connected to this instance that it is shutting down, so they reconnect elsewhere.
+func (g *socketGateway) NotifyShutdown() {
+	g.Server.Of(g.namespace, nil).Local().Emit("server_shutdown", map[string]interface{}{
+		"reconnect": true,
+	})
 }
 
 // endregion
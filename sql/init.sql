@@ -10,11 +10,20 @@ CREATE TYPE public.request_status AS ENUM
     ('pending', 'rejected', 'accepted');
 
 CREATE TYPE public.room_type AS ENUM
-    ('private', 'group');
+    ('private', 'group', 'channel');
 
 CREATE TYPE public.role_type AS ENUM
     ('standard', 'high');
 
+CREATE TYPE public.room_role AS ENUM
+    ('owner', 'admin', 'member');
+
+CREATE TYPE public.presence_visibility AS ENUM
+    ('everyone', 'friends', 'nobody');
+
+CREATE TYPE public.user_status AS ENUM
+    ('available', 'busy', 'away', 'dnd');
+
 CREATE TABLE IF NOT EXISTS public."ROLE"
 (
     role_name character varying(10) COLLATE pg_catalog."default" NOT NULL,
@@ -31,6 +40,12 @@ CREATE TABLE IF NOT EXISTS public."USER"
     "createdAt" timestamp without time zone NOT NULL,
     "updatedAt" timestamp without time zone NOT NULL,
     "deletedAt" timestamp without time zone,
+    "lastSeenAt" timestamp without time zone,
+    presence_visibility presence_visibility NOT NULL DEFAULT 'everyone'::presence_visibility,
+    status user_status NOT NULL DEFAULT 'available'::user_status,
+    status_text character varying(100) COLLATE pg_catalog."default",
+    status_emoji character varying(16) COLLATE pg_catalog."default",
+    "statusExpiresAt" timestamp without time zone,
     CONSTRAINT "USER_pkey" PRIMARY KEY (user_id),
     CONSTRAINT "USER_user_email_key" UNIQUE (user_email),
     CONSTRAINT "USER_user_role_fkey" FOREIGN KEY (user_role)
@@ -51,7 +66,10 @@ CREATE TABLE IF NOT EXISTS public."ROOM"
     "createdAt" timestamp without time zone NOT NULL,
     "deletedAt" timestamp without time zone,
     room_type room_type NOT NULL DEFAULT 'private'::room_type,
+    slow_mode_seconds integer NOT NULL DEFAULT 0,
+    private_key character varying COLLATE pg_catalog."default",
     CONSTRAINT "ROOM_pkey" PRIMARY KEY (room_id),
+    CONSTRAINT "ROOM_private_key_key" UNIQUE (private_key),
     CONSTRAINT user_id FOREIGN KEY (created_user_id)
     REFERENCES public."USER" (user_id) MATCH SIMPLE
                           ON UPDATE NO ACTION
@@ -65,7 +83,14 @@ CREATE TABLE IF NOT EXISTS public."USER_ROOM"
     "createdAt" timestamp without time zone NOT NULL,
     "updatedAt" timestamp without time zone NOT NULL,
     "deletedAt" timestamp without time zone,
+    "clearedAt" timestamp without time zone,
+    "hiddenAt" timestamp without time zone,
+    "bannedAt" timestamp without time zone,
+    "mutedUntil" timestamp without time zone,
+    archived boolean NOT NULL DEFAULT false,
+    pinned_order integer,
     room_id uuid NOT NULL,
+    room_role room_role NOT NULL DEFAULT 'member'::room_role,
     CONSTRAINT "USER_ROOM_pkey" PRIMARY KEY (room_id, user_id),
     CONSTRAINT room_id FOREIGN KEY (room_id)
     REFERENCES public."ROOM" (room_id) MATCH SIMPLE
@@ -91,7 +116,9 @@ CREATE TABLE IF NOT EXISTS public."MESSAGE"
     "deletedAt" timestamp without time zone,
     message_id uuid NOT NULL DEFAULT gen_random_uuid(),
     room_id uuid NOT NULL,
+    client_message_id character varying(64) COLLATE pg_catalog."default",
     CONSTRAINT "MESSAGE_pkey" PRIMARY KEY (message_id),
+    CONSTRAINT "MESSAGE_sender_id_client_message_id_key" UNIQUE (sender_id, client_message_id),
     CONSTRAINT room_id FOREIGN KEY (room_id)
     REFERENCES public."ROOM" (room_id) MATCH SIMPLE
     ON UPDATE NO ACTION
@@ -127,6 +154,64 @@ CREATE TABLE IF NOT EXISTS public."REQUEST"
     NOT VALID
     );
 
+CREATE TABLE IF NOT EXISTS public."HIDDEN_MESSAGE"
+(
+    user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    message_id uuid NOT NULL,
+    "createdAt" timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT "HIDDEN_MESSAGE_pkey" PRIMARY KEY (user_id, message_id),
+    CONSTRAINT message_id FOREIGN KEY (message_id)
+    REFERENCES public."MESSAGE" (message_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE,
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
+
+CREATE TABLE IF NOT EXISTS public."ROOM_INVITE"
+(
+    invite_id uuid NOT NULL DEFAULT gen_random_uuid(),
+    room_id uuid NOT NULL,
+    token character varying COLLATE pg_catalog."default" NOT NULL,
+    created_user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    "expiresAt" timestamp without time zone,
+    max_uses integer,
+    use_count integer NOT NULL DEFAULT 0,
+    revoked_user_id character varying COLLATE pg_catalog."default",
+    "revokedAt" timestamp without time zone,
+    "createdAt" timestamp without time zone NOT NULL,
+    "updatedAt" timestamp without time zone NOT NULL,
+    "deletedAt" timestamp without time zone,
+    CONSTRAINT "ROOM_INVITE_pkey" PRIMARY KEY (invite_id),
+    CONSTRAINT "ROOM_INVITE_token_key" UNIQUE (token),
+    CONSTRAINT room_id FOREIGN KEY (room_id)
+    REFERENCES public."ROOM" (room_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+    CONSTRAINT created_user_id FOREIGN KEY (created_user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
+
+CREATE TABLE IF NOT EXISTS public."ROOM_INVITE_USE"
+(
+    invite_id uuid NOT NULL,
+    user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    "createdAt" timestamp without time zone NOT NULL,
+    CONSTRAINT "ROOM_INVITE_USE_pkey" PRIMARY KEY (invite_id, user_id),
+    CONSTRAINT invite_id FOREIGN KEY (invite_id)
+    REFERENCES public."ROOM_INVITE" (invite_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
+
 INSERT INTO public."ROLE"(role_name)
 VALUES
     ('high'),
@@ -0,0 +1,158 @@
+-- Brings databases created by an older init.sql up to date.
+-- Every statement is idempotent, so the script can be run on any database, e.g. psql -f sql/upgrade.sql.
+
+DO $$
+BEGIN
+CREATE TYPE public.room_role AS ENUM
+    ('owner', 'admin', 'member');
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
+
+DO $$
+BEGIN
+CREATE TYPE public.presence_visibility AS ENUM
+    ('everyone', 'friends', 'nobody');
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
+
+DO $$
+BEGIN
+CREATE TYPE public.user_status AS ENUM
+    ('available', 'busy', 'away', 'dnd');
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
+
+ALTER TYPE public.room_type ADD VALUE IF NOT EXISTS 'channel';
+
+ALTER TABLE public."USER"
+    ADD COLUMN IF NOT EXISTS "lastSeenAt" timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS presence_visibility presence_visibility NOT NULL DEFAULT 'everyone'::presence_visibility,
+    ADD COLUMN IF NOT EXISTS status user_status NOT NULL DEFAULT 'available'::user_status,
+    ADD COLUMN IF NOT EXISTS status_text character varying(100) COLLATE pg_catalog."default",
+    ADD COLUMN IF NOT EXISTS status_emoji character varying(16) COLLATE pg_catalog."default",
+    ADD COLUMN IF NOT EXISTS "statusExpiresAt" timestamp without time zone;
+
+ALTER TABLE public."ROOM"
+    ADD COLUMN IF NOT EXISTS slow_mode_seconds integer NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS private_key character varying COLLATE pg_catalog."default";
+
+ALTER TABLE public."USER_ROOM"
+    ADD COLUMN IF NOT EXISTS "clearedAt" timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS "hiddenAt" timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS "bannedAt" timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS "mutedUntil" timestamp without time zone,
+    ADD COLUMN IF NOT EXISTS archived boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS pinned_order integer,
+    ADD COLUMN IF NOT EXISTS room_role room_role NOT NULL DEFAULT 'member'::room_role;
+
+ALTER TABLE public."MESSAGE"
+    ADD COLUMN IF NOT EXISTS client_message_id character varying(64) COLLATE pg_catalog."default";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "MESSAGE_sender_id_client_message_id_key"
+    ON public."MESSAGE" USING btree (sender_id, client_message_id);
+
+-- Rooms created before roles existed have no owner: their creators become it.
+UPDATE public."USER_ROOM" ur
+SET room_role = 'owner'::room_role
+FROM public."ROOM" r
+WHERE r.room_id = ur.room_id
+  AND r.created_user_id = ur.user_id
+  AND NOT EXISTS (SELECT 1 FROM public."USER_ROOM" owner WHERE owner.room_id = ur.room_id AND owner.room_role = 'owner'::room_role);
+
+-- Key existing private rooms by their member pair, as utils.PrivateRoomKey does (byte order, hence COLLATE "C").
+-- Pairs that already have more than one room keep the key on the oldest live one, so the unique index can be built.
+WITH pairs AS (
+    SELECT ur.room_id,
+           MIN(ur.user_id COLLATE "C") || ':' || MAX(ur.user_id COLLATE "C") AS pair_key
+    FROM public."USER_ROOM" ur
+    JOIN public."ROOM" r ON r.room_id = ur.room_id
+    WHERE r.room_type = 'private'::room_type
+      AND r.private_key IS NULL
+    GROUP BY ur.room_id
+    HAVING COUNT(*) = 2
+), keyed AS (
+    SELECT DISTINCT ON (pairs.pair_key) pairs.room_id, pairs.pair_key
+    FROM pairs
+    JOIN public."ROOM" r ON r.room_id = pairs.room_id
+    WHERE NOT EXISTS (SELECT 1 FROM public."ROOM" taken WHERE taken.private_key = pairs.pair_key)
+    ORDER BY pairs.pair_key, r."deletedAt" IS NOT NULL, r."createdAt"
+)
+UPDATE public."ROOM" r
+SET private_key = keyed.pair_key
+FROM keyed
+WHERE r.room_id = keyed.room_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS "ROOM_private_key_key"
+    ON public."ROOM" USING btree (private_key);
+
+CREATE TABLE IF NOT EXISTS public."HIDDEN_MESSAGE"
+(
+    user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    message_id uuid NOT NULL,
+    "createdAt" timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT "HIDDEN_MESSAGE_pkey" PRIMARY KEY (user_id, message_id),
+    CONSTRAINT message_id FOREIGN KEY (message_id)
+    REFERENCES public."MESSAGE" (message_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE CASCADE,
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
+
+CREATE TABLE IF NOT EXISTS public."ROOM_INVITE"
+(
+    invite_id uuid NOT NULL DEFAULT gen_random_uuid(),
+    room_id uuid NOT NULL,
+    token character varying COLLATE pg_catalog."default" NOT NULL,
+    created_user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    "expiresAt" timestamp without time zone,
+    max_uses integer,
+    use_count integer NOT NULL DEFAULT 0,
+    revoked_user_id character varying COLLATE pg_catalog."default",
+    "revokedAt" timestamp without time zone,
+    "createdAt" timestamp without time zone NOT NULL,
+    "updatedAt" timestamp without time zone NOT NULL,
+    "deletedAt" timestamp without time zone,
+    CONSTRAINT "ROOM_INVITE_pkey" PRIMARY KEY (invite_id),
+    CONSTRAINT "ROOM_INVITE_token_key" UNIQUE (token),
+    CONSTRAINT room_id FOREIGN KEY (room_id)
+    REFERENCES public."ROOM" (room_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+    CONSTRAINT created_user_id FOREIGN KEY (created_user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
+
+ALTER TABLE public."ROOM_INVITE"
+    ADD COLUMN IF NOT EXISTS "deletedAt" timestamp without time zone;
+
+-- Rooms deleted by an older version left their invites behind.
+UPDATE public."ROOM_INVITE" ri
+SET "deletedAt" = r."deletedAt"
+FROM public."ROOM" r
+WHERE r.room_id = ri.room_id
+  AND r."deletedAt" IS NOT NULL
+  AND ri."deletedAt" IS NULL;
+
+CREATE TABLE IF NOT EXISTS public."ROOM_INVITE_USE"
+(
+    invite_id uuid NOT NULL,
+    user_id character varying COLLATE pg_catalog."default" NOT NULL,
+    "createdAt" timestamp without time zone NOT NULL,
+    CONSTRAINT "ROOM_INVITE_USE_pkey" PRIMARY KEY (invite_id, user_id),
+    CONSTRAINT invite_id FOREIGN KEY (invite_id)
+    REFERENCES public."ROOM_INVITE" (invite_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION,
+    CONSTRAINT user_id FOREIGN KEY (user_id)
+    REFERENCES public."USER" (user_id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE NO ACTION
+    );
@@ -0,0 +1,8 @@
+package types
+
+type DeleteType string
+
+const (
+	DeleteForMe       DeleteType = "me"
+	DeleteForEveryone DeleteType = "everyone"
+)
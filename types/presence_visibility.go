@@ -0,0 +1,9 @@
+package types
+
+type PresenceVisibility string
+
+const (
+	VisibleToEveryone PresenceVisibility = "everyone"
+	VisibleToFriends  PresenceVisibility = "friends"
+	VisibleToNobody   PresenceVisibility = "nobody"
+)
@@ -0,0 +1,9 @@
+package types
+
+type RoomRole string
+
+const (
+	Owner  RoomRole = "owner"
+	Admin  RoomRole = "admin"
+	Member RoomRole = "member"
+)
@@ -5,4 +5,5 @@ type RoomType string
 const (
 	Private RoomType = "private"
 	Group   RoomType = "group"
+	Channel RoomType = "channel"
 )
@@ -0,0 +1,10 @@
+package types
+
+type UserStatus string
+
+const (
+	Available    UserStatus = "available"
+	Busy         UserStatus = "busy"
+	Away         UserStatus = "away"
+	DoNotDisturb UserStatus = "dnd"
+)
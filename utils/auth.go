@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -77,6 +79,18 @@ func GetClaims(tokenString string) (map[string]interface{}, error) {
 
 // endregion
 
+// region "GenerateRandomToken" returns a URL-safe token built from the given number of cryptographically random bytes.
+func GenerateRandomToken(byteLength int) (string, error) {
+	randomBytes := make([]byte, byteLength)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err // Return an error if the system random source fails.
+	}
+
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// endregion
+
 // region UserInfo holds user session information.
 type UserInfo struct {
 	Email string // User email
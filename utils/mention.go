@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// region "IsMentioned" reports whether the message mentions the user as "@userName".
+func IsMentioned(message, userName string) bool {
+	if userName == "" {
+		return false
+	}
+
+	mention := "@" + userName
+	for index := strings.Index(message, mention); index != -1; {
+		end := index + len(mention)
+
+		// The mention must stand on its own: not inside a word such as "mail@bob", nor the start of a longer name such as "@bobby".
+		before, _ := utf8.DecodeLastRuneInString(message[:index])
+		after, _ := utf8.DecodeRuneInString(message[end:])
+		if (index == 0 || !isWordRune(before)) && (end == len(message) || !isWordRune(after)) {
+			return true
+		}
+
+		next := strings.Index(message[end:], mention)
+		if next == -1 {
+			break
+		}
+		index = end + next
+	}
+
+	return false
+}
+
+// endregion
+
+// region "isWordRune" reports whether the rune can be part of a username.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// endregion
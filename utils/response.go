@@ -27,6 +27,18 @@ func NewGetResponse(rowCount int, data interface{}) getResponse {
 	return getResponse{RowCount: rowCount, Data: data}
 }
 
+type paginatedResponse struct {
+	RowCount   int         `json:"rowCount"`
+	TotalCount int64       `json:"totalCount"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Data       interface{} `json:"data"`
+}
+
+func NewPaginatedResponse(rowCount int, totalCount int64, page, limit int, data interface{}) paginatedResponse {
+	return paginatedResponse{RowCount: rowCount, TotalCount: totalCount, Page: page, Limit: limit, Data: data}
+}
+
 type loginResponse struct {
 	Message string `json:"message"`
 }
@@ -0,0 +1,11 @@
+package utils
+
+// region "PrivateRoomKey" returns the canonical key of the private room between two users, independent of their order.
+func PrivateRoomKey(userId1, userId2 string) string {
+	if userId2 < userId1 {
+		userId1, userId2 = userId2, userId1
+	}
+	return userId1 + ":" + userId2
+}
+
+// endregion
@@ -1,29 +1,28 @@
 package utils
 
 import (
-	"github.com/zishang520/engine.io/utils"
-	socketUtils "github.com/zishang520/engine.io/utils"
+	"log/slog"
 )
 
 // region "ExtractArgs" extracts the data and callback function from socket arguments.
 func ExtractArgs(args []any) (map[string]interface{}, func([]interface{}, error)) {
 	// Check if there are enough arguments
 	if len(args) < 2 {
-		utils.Log().Error(`not enough arguments`) // Log an error if not enough arguments are provided
+		slog.Debug("socket event has too few arguments", "count", len(args))
 		return nil, nil
 	}
 
 	// Extract data from the first argument and check its type
 	data, ok := args[0].(map[string]interface{})
 	if !ok {
-		utils.Log().Error(`socket message type error`) // Log an error if the data type is incorrect
+		slog.Debug("socket event data is not an object")
 		return nil, nil
 	}
 
 	// Extract the callback function from the second argument and check its type
 	callback, ok := args[1].(func([]interface{}, error))
 	if !ok {
-		utils.Log().Error(`callback function type error`) // Log an error if the callback type is incorrect
+		slog.Debug("socket event has no acknowledgement callback")
 		return nil, nil
 	}
 
@@ -34,8 +33,10 @@ func ExtractArgs(args []any) (map[string]interface{}, func([]interface{}, error)
 
 // region Response defines a structured response format for socket communication.
 type Response struct {
-	Status  string `json:"status"`  // Response status (success/error)
-	Message string `json:"message"` // Response message
+	Status     string `json:"status"`                // Response status (success/error)
+	Message    string `json:"message"`               // Response message
+	RetryAfter int    `json:"retry_after,omitempty"` // Seconds to wait before retrying, when throttled
+	Data       any    `json:"data,omitempty"`        // Payload of successful requests that return data
 }
 
 // endregion
@@ -48,17 +49,32 @@ func SendResponse(callback func([]interface{}, error), status, message string) {
 
 // endregion
 
-// region "LogError" logs an error message and sends an error response
+// region "LogError" sends an error response; the socket adapter logs it with the socket and user IDs
 func LogError(callback func([]interface{}, error), message string) {
-	socketUtils.Log().Error(message)         // Log the error message
 	SendResponse(callback, "error", message) // Send an error response
 }
 
 // endregion
 
+// region "SendRetryResponse" sends an error response telling the client how many seconds to wait before retrying
+func SendRetryResponse(callback func([]interface{}, error), message string, retryAfterSeconds int) {
+	response := []interface{}{Response{Status: "error", Message: message, RetryAfter: retryAfterSeconds}} // Create a response object
+	callback(response, nil)                                                                               // Invoke the callback with the response
+}
+
+// endregion
+
 // region "LogSuccess" sends a success response
 func LogSuccess(callback func([]interface{}, error), message string) {
 	SendResponse(callback, "success", message) // Send a success response
 }
 
 // endregion
+
+// region "SendDataResponse" sends a success response carrying the requested data
+func SendDataResponse(callback func([]interface{}, error), message string, data any) {
+	response := []interface{}{Response{Status: "success", Message: message, Data: data}} // Create a response object
+	callback(response, nil)                                                              // Invoke the callback with the response
+}
+
+// endregion